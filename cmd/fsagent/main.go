@@ -4,21 +4,40 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
 	"github.com/luongdev/fsagent/pkg/calculator"
+	"github.com/luongdev/fsagent/pkg/calculator/sink"
 	"github.com/luongdev/fsagent/pkg/config"
 	"github.com/luongdev/fsagent/pkg/connection"
 	"github.com/luongdev/fsagent/pkg/exporter"
+	"github.com/luongdev/fsagent/pkg/health"
+	"github.com/luongdev/fsagent/pkg/lifecycle"
 	"github.com/luongdev/fsagent/pkg/logger"
+	"github.com/luongdev/fsagent/pkg/metrics"
 	"github.com/luongdev/fsagent/pkg/processor"
 	"github.com/luongdev/fsagent/pkg/server"
 	"github.com/luongdev/fsagent/pkg/store"
 )
 
+// Shutdown priorities: components stop in descending order so that
+// request-facing components (HTTP, connections) stop before the subsystems
+// they depend on (processing, exporting, storage).
+const (
+	priorityHTTPServer      = 50
+	priorityConnectionMgr   = 40
+	priorityEventProcessor  = 30
+	priorityMetricsExporter = 20
+	priorityStateStore      = 10
+)
+
 var (
 	configPath = flag.String("config", "config.yaml", "Path to configuration file")
 	version    = flag.Bool("version", false, "Print version and exit")
@@ -65,24 +84,40 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Initialize logger with configured level and format
+	// Initialize logger with configured level, format, and output sinks
 	logLevel := logger.ParseLogLevel(cfg.Logging.Level)
-	logger.InitWithFormat(logLevel, cfg.Logging.Format)
+	if err := logger.InitWithSinks(logLevel, cfg.Logging.Format, toSinkConfigs(cfg.Logging.Sinks)); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize log sinks: %v\n", err)
+		os.Exit(1)
+	}
 	logger.Info("FSAgent starting with log level: %s, format: %s", cfg.Logging.Level, cfg.Logging.Format)
 
+	logger.ApplyPackageLevels(cfg.Logging.Packages)
 	logger.Info("Loaded configuration with %d FreeSWITCH instance(s)", len(cfg.FreeSwitchInstances))
 
-	// Perform startup validation
-	if err := validateStartup(cfg); err != nil {
-		logger.Error("Startup validation failed: %v", err)
-		os.Exit(1)
-	}
-	logger.Info("Startup validation completed successfully")
-
 	// Create context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Perform startup validation via retrying health probes
+	healthChecker := newStartupChecker(cfg)
+	if err := healthChecker.RunWithBackoff(ctx, health.DefaultBackoff); err != nil {
+		logger.Fatal("Startup validation failed: %v", err)
+	}
+	logger.Info("Startup validation completed successfully")
+
+	// lm coordinates shutdown of every subsystem below in place of a
+	// hand-written teardown sequence; each component registers itself right
+	// after construction instead of one shutdown function hard-coding them all.
+	lm := lifecycle.NewManager()
+	logger.RegisterShutdownHook(func() {
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer shutdownCancel()
+		if err := lm.Shutdown(shutdownCtx); err != nil {
+			logger.Error("Error during fatal-path shutdown: %v", err)
+		}
+	})
+
 	// Initialize State Store
 	logger.Info("Initializing state store: type=%s", cfg.Storage.Type)
 
@@ -101,36 +136,58 @@ func main() {
 	}
 
 	if err != nil {
-		logger.Error("Failed to initialize state store: %v", err)
-		os.Exit(1)
+		logger.Fatal("Failed to initialize state store: %v", err)
 	}
-	defer stateStore.Close()
+	lm.Register("state store", priorityStateStore, func(context.Context) error {
+		return stateStore.Close()
+	})
 	logger.Info("State store initialized successfully")
 
 	// Initialize RTCP Calculator
 	rtcpCalculator := calculator.NewRTCPCalculator(stateStore)
 	logger.Info("RTCP calculator initialized")
 
+	// Initialize Call Aggregator: correlates A-leg/B-leg QoSMetrics into a
+	// single per-call report instead of downstream consumers seeing two
+	// uncorrelated ones. Built before the QoS calculator so it can be wired
+	// in as the calculator's per-event aggregation target below.
+	callAggregator := calculator.NewCallAggregator(stateStore)
+	callAggregator.OnReport(func(report *calculator.CallQoSReport) {
+		logger.Info("Call QoS report: correlation_id=%s legs=%d weighted_avg_mos=%.2f worst_leg_mos=%.2f codec_mismatch=%v",
+			report.CorrelationID, len(report.Legs), report.WeightedAvgMOS, report.WorstLegMOS, report.CodecMismatch)
+	})
+	if err := callAggregator.Restore(ctx); err != nil {
+		logger.Warn("Failed to restore pending call aggregation buffer: %v", err)
+	}
+	logger.Info("Call aggregator initialized")
+
 	// Initialize QoS Calculator
-	qosCalculator := calculator.NewQoSCalculator(stateStore)
+	qosOpts := []calculator.Option{calculator.WithEModel(cfg.Calculator.UseEModel), calculator.WithCallAggregator(callAggregator)}
+	metricsSinks, promSinkMounts := buildMetricsSinks(cfg.Calculator.Sinks)
+	if len(metricsSinks) > 0 {
+		qosOpts = append(qosOpts, calculator.WithSinks(metricsSinks...))
+		logger.Info("QoS metrics sinks configured: count=%d", len(metricsSinks))
+	}
+	qosCalculator := calculator.NewQoSCalculator(stateStore, qosOpts...)
 	logger.Info("QoS calculator initialized")
 
 	// Initialize OpenTelemetry Metrics Exporter
 	metricsExporter, err := exporter.NewMetricsExporter(&cfg.OpenTelemetry)
 	if err != nil {
-		logger.Error("Failed to initialize metrics exporter: %v", err)
-		os.Exit(1)
+		logger.Fatal("Failed to initialize metrics exporter: %v", err)
 	}
+	lm.Register("metrics exporter", priorityMetricsExporter, metricsExporter.Stop)
 	logger.Info("Metrics exporter initialized: endpoint=%s", cfg.OpenTelemetry.Endpoint)
 
 	// Initialize Event Processor
 	eventProcessor := processor.NewEventProcessor(stateStore, rtcpCalculator, qosCalculator, metricsExporter, cfg.Events.RTCP, cfg.Events.QoS)
 	logger.Info("Event processor initialized: rtcp=%v, qos=%v", cfg.Events.RTCP, cfg.Events.QoS)
 	if err := eventProcessor.Start(ctx); err != nil {
-		logger.Error("Failed to start event processor: %v", err)
-		os.Exit(1)
+		logger.Fatal("Failed to start event processor: %v", err)
 	}
-	defer eventProcessor.Stop()
+	lm.Register("event processor", priorityEventProcessor, func(context.Context) error {
+		return eventProcessor.Stop()
+	})
 	logger.Info("Event processor started")
 
 	// Initialize Connection Manager
@@ -146,7 +203,9 @@ func main() {
 	if err := connManager.Start(ctx); err != nil {
 		logger.Warn("Some connections failed to start: %v", err)
 	}
-	defer connManager.Stop()
+	lm.Register("connection manager", priorityConnectionMgr, func(context.Context) error {
+		return connManager.Stop()
+	})
 
 	// Get connection status
 	connStatus := connManager.GetStatus()
@@ -158,53 +217,225 @@ func main() {
 	}
 
 	if activeConnections == 0 {
-		logger.Error("No FreeSWITCH connections established")
-		os.Exit(1)
+		logger.Fatal("No FreeSWITCH connections established")
 	}
 
 	logger.Info("FSAgent started successfully with %d active connection(s)", activeConnections)
 
+	// Initialize PeriodicSampler: polls every active channel's uuid_dump on
+	// an interval so long calls get interim QoS visibility before
+	// CHANNEL_DESTROY produces the final report. Requires both the state
+	// store to support ActiveChannelIDs and the connection manager to issue
+	// API commands; skip sampling rather than failing startup if either is
+	// unavailable, since mid-call sampling is an enhancement over the
+	// final-report path, not load-bearing for it.
+	if apiClient, ok := connManager.(calculator.FSApiClient); ok {
+		sampler, err := calculator.NewPeriodicSampler(stateStore, apiClient, qosCalculator)
+		if err != nil {
+			logger.Warn("Periodic QoS sampling disabled: %v", err)
+		} else {
+			sampler.Start(ctx)
+			lm.Register("periodic sampler", priorityEventProcessor, func(context.Context) error {
+				return sampler.Stop()
+			})
+			logger.Info("Periodic QoS sampler started: interval=%s", calculator.DefaultSampleInterval)
+		}
+	} else {
+		logger.Warn("Periodic QoS sampling disabled: connection manager does not implement FSApiClient")
+	}
+
+	// Continuously poll FreeSWITCH connection status so the fsConnections
+	// gauge and /readyz stay accurate as instances reconnect or drop, instead
+	// of only being checked once at startup.
+	go pollConnectionStatus(ctx, connManager, healthChecker)
+
 	// Initialize and start HTTP server
 	httpServer := server.NewHTTPServer(cfg.HTTP.Port, connManager)
+	httpServer.Handle("/admin/log-level", logger.AdminLogLevelHandler())
+	httpServer.Handle("/metrics", newMetricsHandler())
+	httpServer.Handle("/healthz", healthChecker.LivenessHandler())
+	httpServer.Handle("/readyz", healthChecker.ReadinessHandler())
+	for _, mount := range promSinkMounts {
+		httpServer.Handle(mount.path, mount.sink.Handler())
+	}
 	if err := httpServer.Start(ctx); err != nil {
-		logger.Error("Failed to start HTTP server: %v", err)
-		os.Exit(1)
+		logger.Fatal("Failed to start HTTP server: %v", err)
 	}
-	defer httpServer.Stop()
+	lm.Register("HTTP server", priorityHTTPServer, func(context.Context) error {
+		return httpServer.Stop()
+	})
 	logger.Info("HTTP server started on port %d", cfg.HTTP.Port)
 
-	// Wait for interrupt signal
+	// Wait for interrupt signal, reloading logging.packages on SIGHUP
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
 
-	<-sigChan
+	for sig := range sigChan {
+		if sig == syscall.SIGHUP {
+			reloadLogLevels(*configPath)
+			continue
+		}
+		break
+	}
 	logger.Info("Shutdown signal received, initiating graceful shutdown...")
 
-	// Perform graceful shutdown
-	gracefulShutdown(ctx, cancel, connManager, eventProcessor, metricsExporter, stateStore, httpServer)
+	// Cancel the main context to signal all components, then run the
+	// registered shutdown sequence.
+	cancel()
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer shutdownCancel()
+	if err := lm.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Graceful shutdown completed with errors: %v", err)
+	}
 
 	logger.Info("FSAgent stopped successfully")
 }
 
-// validateStartup performs startup validation of all components
-func validateStartup(cfg *config.Config) error {
-	logger.Info("Performing startup validation...")
+// toSinkConfigs adapts the config file's logging.sinks entries to the
+// logger package's sink abstraction.
+func toSinkConfigs(sinks []config.LogSinkConfig) []logger.SinkConfig {
+	result := make([]logger.SinkConfig, 0, len(sinks))
+	for _, sink := range sinks {
+		result = append(result, logger.SinkConfig{
+			Type:       logger.SinkType(sink.Type),
+			Path:       sink.Path,
+			MaxSizeMB:  sink.MaxSizeMB,
+			MaxAgeDays: sink.MaxAgeDays,
+			MaxBackups: sink.MaxBackups,
+			Compress:   sink.Compress,
+			Network:    sink.Network,
+			Address:    sink.Address,
+			Tag:        sink.Tag,
+		})
+	}
+	return result
+}
+
+// promSinkMount pairs a configured Prometheus metrics sink with the path it
+// should be mounted at, once the HTTP server exists.
+type promSinkMount struct {
+	path string
+	sink *sink.PrometheusSink
+}
 
-	// Validate State Store connection
-	logger.Info("Validating state store connection...")
-	if err := validateStateStore(cfg); err != nil {
-		return fmt.Errorf("state store validation failed: %w", err)
+// buildMetricsSinks adapts the config file's calculator.sinks entries into
+// concrete calculator.Sink implementations. Misconfigured entries are logged
+// and skipped rather than failing startup, since metrics sinks are an
+// observability add-on and not load-bearing for call processing. Prometheus
+// sinks are returned separately alongside their configured mount path, since
+// the HTTP server doesn't exist yet at the point the QoS calculator is built.
+func buildMetricsSinks(sinks []config.MetricsSinkConfig) ([]calculator.Sink, []promSinkMount) {
+	result := make([]calculator.Sink, 0, len(sinks))
+	var promMounts []promSinkMount
+	for _, s := range sinks {
+		switch s.Type {
+		case "influx_udp":
+			influxSink, err := sink.NewInfluxUDPSink(s.Address)
+			if err != nil {
+				logger.Error("Failed to initialize influx UDP metrics sink: %v", err)
+				continue
+			}
+			result = append(result, influxSink)
+		case "influx_http":
+			result = append(result, sink.NewInfluxHTTPSink(s.WriteURL))
+		case "prometheus":
+			promSink := sink.NewPrometheusSink()
+			result = append(result, promSink)
+			promMounts = append(promMounts, promSinkMount{path: s.Path, sink: promSink})
+		case "jsonlines":
+			if s.Path == "" {
+				result = append(result, sink.NewJSONLinesSink(os.Stdout))
+				continue
+			}
+			jsonSink, err := sink.NewJSONLinesFileSink(s.Path)
+			if err != nil {
+				logger.Error("Failed to initialize JSON-lines metrics sink: %v", err)
+				continue
+			}
+			result = append(result, jsonSink)
+		default:
+			logger.Warn("Unknown metrics sink type: %s", s.Type)
+		}
 	}
-	logger.Info("State store validation successful")
+	return result, promMounts
+}
+
+// newMetricsHandler builds the /metrics handler, registering FSAgent's own
+// counters/gauges/histogram alongside the standard Go runtime and process
+// collectors so a single scrape covers both.
+func newMetricsHandler() http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(prometheus.NewGoCollector())
+	registry.MustRegister(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
 
-	// Validate OTel endpoint connectivity
-	logger.Info("Validating OpenTelemetry endpoint connectivity...")
-	if err := validateOTelEndpoint(cfg); err != nil {
-		return fmt.Errorf("OTel endpoint validation failed: %w", err)
+	if err := metrics.GetMetrics().Register(registry); err != nil {
+		logger.Error("Failed to register internal metrics collectors: %v", err)
 	}
-	logger.Info("OpenTelemetry endpoint validation successful")
 
-	return nil
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// reloadLogLevels re-reads logging.packages from the config file and applies
+// it to the running logger, allowing operators to bump verbosity for a single
+// package (e.g. `kill -HUP <pid>`) without restarting FSAgent.
+func reloadLogLevels(configPath string) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		logger.Error("Failed to reload configuration on SIGHUP: %v", err)
+		return
+	}
+	logger.ApplyPackageLevels(cfg.Logging.Packages)
+	logger.Info("Reloaded log level overrides from %s on SIGHUP", configPath)
+}
+
+// fsConnectionStatusInterval is how often pollConnectionStatus re-checks
+// FreeSWITCH connection status.
+const fsConnectionStatusInterval = 5 * time.Second
+
+// pollConnectionStatus periodically refreshes the fsConnections gauge and
+// flips readiness to false once every configured FreeSWITCH instance has
+// disconnected, so a Kubernetes readiness probe pulls the pod from
+// load-balancer rotation without FSAgent being terminated.
+func pollConnectionStatus(ctx context.Context, connManager connection.ConnectionManager, checker *health.Checker) {
+	ticker := time.NewTicker(fsConnectionStatusInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			active := 0
+			for instance, status := range connManager.GetStatus() {
+				metrics.GetMetrics().SetFSConnectionStatus(instance, status.Connected)
+				if status.Connected {
+					active++
+				}
+			}
+			checker.SetReady(active > 0)
+		}
+	}
+}
+
+// newStartupChecker builds the health.Checker that gates startup: each probe
+// retries with exponential backoff instead of the old one-shot
+// connect-then-discard validation, so a momentarily unreachable Redis or
+// OTLP collector during a rolling deploy doesn't fail the whole process.
+func newStartupChecker(cfg *config.Config) *health.Checker {
+	checker := health.NewChecker()
+	checker.Register(health.Probe{
+		Name: "redis",
+		Check: func(ctx context.Context) error {
+			return validateStateStore(cfg)
+		},
+	})
+	checker.Register(health.Probe{
+		Name: "otlp",
+		Check: func(ctx context.Context) error {
+			return validateOTelEndpoint(cfg)
+		},
+	})
+	return checker
 }
 
 // validateStateStore validates the state store connection
@@ -285,62 +516,3 @@ func validateOTelEndpoint(cfg *config.Config) error {
 	return nil
 }
 
-// gracefulShutdown performs graceful shutdown of all components
-func gracefulShutdown(
-	ctx context.Context,
-	cancel context.CancelFunc,
-	connManager connection.ConnectionManager,
-	eventProcessor processor.EventProcessor,
-	metricsExporter exporter.MetricsExporter,
-	stateStore store.StateStore,
-	httpServer *server.HTTPServer,
-) {
-	// Create shutdown context with timeout
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer shutdownCancel()
-
-	// Cancel main context to signal all components
-	cancel()
-
-	// Step 1: Stop HTTP server (stop accepting new requests)
-	logger.Info("Stopping HTTP server...")
-	if err := httpServer.Stop(); err != nil {
-		logger.Error("Error stopping HTTP server: %v", err)
-	} else {
-		logger.Info("HTTP server stopped")
-	}
-
-	// Step 2: Stop Connection Manager (stop receiving new events)
-	logger.Info("Stopping connection manager...")
-	if err := connManager.Stop(); err != nil {
-		logger.Error("Error stopping connection manager: %v", err)
-	} else {
-		logger.Info("Connection manager stopped")
-	}
-
-	// Step 3: Stop Event Processor (finish processing queued events)
-	logger.Info("Stopping event processor...")
-	if err := eventProcessor.Stop(); err != nil {
-		logger.Error("Error stopping event processor: %v", err)
-	} else {
-		logger.Info("Event processor stopped")
-	}
-
-	// Step 4: Flush and stop Metrics Exporter
-	logger.Info("Flushing and stopping metrics exporter...")
-	if err := metricsExporter.Stop(shutdownCtx); err != nil {
-		logger.Error("Error stopping metrics exporter: %v", err)
-	} else {
-		logger.Info("Metrics exporter stopped")
-	}
-
-	// Step 5: Close State Store
-	logger.Info("Closing state store...")
-	if err := stateStore.Close(); err != nil {
-		logger.Error("Error closing state store: %v", err)
-	} else {
-		logger.Info("State store closed")
-	}
-
-	logger.Info("Graceful shutdown completed")
-}
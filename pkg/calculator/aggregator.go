@@ -0,0 +1,312 @@
+package calculator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/luongdev/fsagent/pkg/store"
+)
+
+// DefaultAggregationWindow is how long CallAggregator waits after the first
+// leg of a call is destroyed before emitting a CallQoSReport, giving the
+// other leg a chance to tear down too.
+const DefaultAggregationWindow = 5 * time.Second
+
+// pendingCallBufferHeadroom is added to the grace window when setting a
+// durable buffer entry's TTL, so the entry outlives the window it's
+// covering by enough margin for a restart-and-Restore cycle to find it
+// before it would otherwise expire.
+const pendingCallBufferHeadroom = 30 * time.Second
+
+// pendingCallStore is the subset of store.StateStore CallAggregator needs to
+// durably buffer pending legs across a restart: store.StateStore's Get/Set/
+// Delete are shaped around *ChannelState, not an arbitrary per-call buffer,
+// so durable buffering is only available when the configured store
+// additionally implements this. Mirrors the channelLister pattern in
+// sampler.go - an optional capability the aggregator type-asserts for,
+// falling back to in-memory-only (logged, not silent) when absent.
+type pendingCallStore interface {
+	SetPendingCall(ctx context.Context, correlationID string, legs []byte, ttl time.Duration) error
+	GetPendingCall(ctx context.Context, correlationID string) ([]byte, error)
+	DeletePendingCall(ctx context.Context, correlationID string) error
+	PendingCallIDs(ctx context.Context) ([]string, error)
+}
+
+// CallQoSReport is the single, correlated report emitted per call once both
+// (or as many as arrived within the grace window) legs have been seen,
+// replacing the two uncorrelated per-channel QoSMetrics reports downstream
+// consumers previously had to stitch together themselves.
+type CallQoSReport struct {
+	CorrelationID string
+	DomainName    string
+	Legs          []*QoSMetrics
+
+	WeightedAvgMOS float64 // weighted by each leg's TotalPackets
+	WorstLegMOS    float64
+
+	TotalPacketLoss int64
+	TotalPackets    int64
+	TotalBytes      int64
+	MaxJitter       float64
+
+	CodecMismatch bool // true when legs used different codecs
+
+	// SIP endpoints of both legs, in the order legs were received.
+	Endpoints []CallEndpoint
+}
+
+// CallEndpoint identifies one leg's media endpoint in a CallQoSReport.
+type CallEndpoint struct {
+	ChannelID string
+	SrcIP     string
+	SrcPort   uint16
+	DstIP     string
+	DstPort   uint16
+}
+
+// pendingCall buffers legs for a single CorrelationID until its grace window
+// expires.
+type pendingCall struct {
+	legs  []*QoSMetrics
+	timer *time.Timer
+}
+
+// CallAggregator buffers per-leg QoSMetrics by CorrelationID and emits a
+// single aggregated CallQoSReport once the grace window after the first leg
+// elapses.
+//
+// Each Emit durably persists its call's buffered legs via persist, when the
+// configured store supports it, so Restore can replay any calls still
+// pending their grace window after a restart instead of silently dropping
+// them. persist is nil (in-memory only, a restart mid-window does drop the
+// report) when the configured store doesn't implement pendingCallStore.
+type CallAggregator struct {
+	persist pendingCallStore
+	window  time.Duration
+
+	mu      sync.Mutex
+	pending map[string]*pendingCall
+
+	callbacksMu sync.RWMutex
+	callbacks   []func(*CallQoSReport)
+}
+
+// NewCallAggregator creates an aggregator with DefaultAggregationWindow,
+// durably buffering pending legs in st when st implements pendingCallStore.
+func NewCallAggregator(st store.StateStore) *CallAggregator {
+	return NewCallAggregatorWithWindow(st, DefaultAggregationWindow)
+}
+
+// NewCallAggregatorWithWindow is like NewCallAggregator but overrides the
+// grace window, primarily for tests.
+func NewCallAggregatorWithWindow(st store.StateStore, window time.Duration) *CallAggregator {
+	a := &CallAggregator{
+		window:  window,
+		pending: make(map[string]*pendingCall),
+	}
+	if persist, ok := st.(pendingCallStore); ok {
+		a.persist = persist
+	} else {
+		calcLog.Warn("Configured state store does not support durable call buffering; a restart mid-grace-window will drop in-flight CallQoSReports")
+	}
+	return a
+}
+
+// withPersist overrides the durable buffer directly, letting tests exercise
+// the persistence path with a fake pendingCallStore without needing a full
+// store.StateStore implementation.
+func (a *CallAggregator) withPersist(p pendingCallStore) *CallAggregator {
+	a.persist = p
+	return a
+}
+
+// OnReport registers a callback invoked with each aggregated CallQoSReport.
+// Callbacks run synchronously on the aggregator's grace-window timer
+// goroutine (or, for a leg with an empty CorrelationID, on Emit's caller),
+// so they should hand off slow work (e.g. to a sink) rather than blocking.
+func (a *CallAggregator) OnReport(fn func(*CallQoSReport)) {
+	a.callbacksMu.Lock()
+	defer a.callbacksMu.Unlock()
+	a.callbacks = append(a.callbacks, fn)
+}
+
+// Emit buffers a single leg's metrics under its CorrelationID. The first leg
+// for a given call starts the grace window timer; subsequent legs just
+// append. Once the window elapses, the buffered legs are aggregated into a
+// CallQoSReport and every registered callback is invoked.
+func (a *CallAggregator) Emit(ctx context.Context, metrics *QoSMetrics) {
+	if metrics.CorrelationID == "" {
+		calcLog.Warn("CallAggregator.Emit called with empty CorrelationID for channel %s, emitting as a single-leg call", metrics.ChannelID)
+		a.flush(ctx, metrics.CorrelationID, []*QoSMetrics{metrics})
+		return
+	}
+
+	a.mu.Lock()
+	call, exists := a.pending[metrics.CorrelationID]
+	if !exists {
+		correlationID := metrics.CorrelationID
+		call = &pendingCall{}
+		call.timer = time.AfterFunc(a.window, func() {
+			a.mu.Lock()
+			legs := call.legs
+			delete(a.pending, correlationID)
+			a.mu.Unlock()
+			a.flush(context.Background(), correlationID, legs)
+		})
+		a.pending[metrics.CorrelationID] = call
+	}
+	call.legs = append(call.legs, metrics)
+	legsSnapshot := append([]*QoSMetrics(nil), call.legs...)
+	a.mu.Unlock()
+
+	a.persistPending(ctx, metrics.CorrelationID, legsSnapshot)
+}
+
+// persistPending writes legs to the durable buffer under correlationID, a
+// no-op when the configured store doesn't support it. Failures are logged
+// and otherwise swallowed: the in-memory buffer (what Emit's caller actually
+// waits on) is unaffected either way.
+func (a *CallAggregator) persistPending(ctx context.Context, correlationID string, legs []*QoSMetrics) {
+	if a.persist == nil {
+		return
+	}
+	raw, err := json.Marshal(legs)
+	if err != nil {
+		calcLog.Warn("Failed to encode pending call for durable buffering: correlation_id=%s error=%v", correlationID, err)
+		return
+	}
+	if err := a.persist.SetPendingCall(ctx, correlationID, raw, a.window+pendingCallBufferHeadroom); err != nil {
+		calcLog.Warn("Failed to persist pending call: correlation_id=%s error=%v", correlationID, err)
+	}
+}
+
+// flush builds a CallQoSReport from the buffered legs and notifies callbacks.
+func (a *CallAggregator) flush(ctx context.Context, correlationID string, legs []*QoSMetrics) {
+	if len(legs) == 0 {
+		return
+	}
+
+	if a.persist != nil && correlationID != "" {
+		if err := a.persist.DeletePendingCall(ctx, correlationID); err != nil {
+			calcLog.Warn("Failed to delete durable buffer for completed call: correlation_id=%s error=%v", correlationID, err)
+		}
+	}
+
+	report := aggregateLegs(correlationID, legs)
+
+	a.callbacksMu.RLock()
+	callbacks := make([]func(*CallQoSReport), len(a.callbacks))
+	copy(callbacks, a.callbacks)
+	a.callbacksMu.RUnlock()
+
+	for _, cb := range callbacks {
+		cb(report)
+	}
+}
+
+// Restore reloads every call still buffered in the durable store - left over
+// from a restart during their grace window - and re-arms a fresh window for
+// each from now (the original deadline isn't itself persisted, so a
+// restored call gets a full new window rather than resuming a partial one).
+// A no-op when the configured store doesn't support durable buffering. Call
+// this once, right after registering OnReport callbacks and before the
+// first Emit.
+func (a *CallAggregator) Restore(ctx context.Context) error {
+	if a.persist == nil {
+		return nil
+	}
+
+	correlationIDs, err := a.persist.PendingCallIDs(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list durably buffered pending calls: %w", err)
+	}
+
+	for _, correlationID := range correlationIDs {
+		raw, err := a.persist.GetPendingCall(ctx, correlationID)
+		if err != nil {
+			calcLog.Warn("Failed to load durably buffered pending call: correlation_id=%s error=%v", correlationID, err)
+			continue
+		}
+
+		var legs []*QoSMetrics
+		if err := json.Unmarshal(raw, &legs); err != nil {
+			calcLog.Warn("Failed to decode durably buffered pending call: correlation_id=%s error=%v", correlationID, err)
+			continue
+		}
+		if len(legs) == 0 {
+			continue
+		}
+
+		cid := correlationID
+		call := &pendingCall{legs: legs}
+		call.timer = time.AfterFunc(a.window, func() {
+			a.mu.Lock()
+			legs := call.legs
+			delete(a.pending, cid)
+			a.mu.Unlock()
+			a.flush(context.Background(), cid, legs)
+		})
+
+		a.mu.Lock()
+		a.pending[correlationID] = call
+		a.mu.Unlock()
+
+		calcLog.Info("Restored pending call from durable buffer: correlation_id=%s legs=%d", correlationID, len(legs))
+	}
+	return nil
+}
+
+// aggregateLegs computes the weighted averages, worst-leg MOS, sums, and
+// codec-mismatch flag for a finished call's legs.
+func aggregateLegs(correlationID string, legs []*QoSMetrics) *CallQoSReport {
+	report := &CallQoSReport{
+		CorrelationID: correlationID,
+		DomainName:    legs[0].DomainName,
+		Legs:          legs,
+		WorstLegMOS:   legs[0].MOSScore,
+	}
+
+	var weightedMOSSum float64
+	var weightTotal int64
+	codec := legs[0].CodecName
+
+	for _, leg := range legs {
+		weight := leg.TotalPackets
+		if weight == 0 {
+			weight = 1 // unweighted fallback so a zero-traffic leg still counts
+		}
+		weightedMOSSum += leg.MOSScore * float64(weight)
+		weightTotal += weight
+
+		if leg.MOSScore < report.WorstLegMOS {
+			report.WorstLegMOS = leg.MOSScore
+		}
+		if leg.CodecName != codec {
+			report.CodecMismatch = true
+		}
+		if leg.MaxJitter > report.MaxJitter {
+			report.MaxJitter = leg.MaxJitter
+		}
+
+		report.TotalPacketLoss += leg.PacketLoss
+		report.TotalPackets += leg.TotalPackets
+		report.TotalBytes += leg.TotalBytes
+
+		report.Endpoints = append(report.Endpoints, CallEndpoint{
+			ChannelID: leg.ChannelID,
+			SrcIP:     leg.SrcIP,
+			SrcPort:   leg.SrcPort,
+			DstIP:     leg.DstIP,
+			DstPort:   leg.DstPort,
+		})
+	}
+
+	if weightTotal > 0 {
+		report.WeightedAvgMOS = weightedMOSSum / float64(weightTotal)
+	}
+
+	return report
+}
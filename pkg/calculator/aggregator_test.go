@@ -0,0 +1,194 @@
+package calculator
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakePendingCallStore is an in-memory pendingCallStore stand-in, letting
+// tests exercise CallAggregator's durable-buffering path without a real
+// store.StateStore implementation.
+type fakePendingCallStore struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func newFakePendingCallStore() *fakePendingCallStore {
+	return &fakePendingCallStore{data: make(map[string][]byte)}
+}
+
+func (f *fakePendingCallStore) SetPendingCall(_ context.Context, correlationID string, legs []byte, _ time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.data[correlationID] = legs
+	return nil
+}
+
+func (f *fakePendingCallStore) GetPendingCall(_ context.Context, correlationID string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.data[correlationID], nil
+}
+
+func (f *fakePendingCallStore) DeletePendingCall(_ context.Context, correlationID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.data, correlationID)
+	return nil
+}
+
+func (f *fakePendingCallStore) PendingCallIDs(_ context.Context) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ids := make([]string, 0, len(f.data))
+	for id := range f.data {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func TestCallAggregator_EmitPersistsAndFlushDeletesDurableBuffer(t *testing.T) {
+	store := newFakePendingCallStore()
+	agg := NewCallAggregatorWithWindow(nil, time.Hour).withPersist(store) // long window: assert before the timer fires
+
+	agg.Emit(context.Background(), &QoSMetrics{ChannelID: "a-leg", CorrelationID: "call-1", MOSScore: 4.0})
+
+	if _, err := store.GetPendingCall(context.Background(), "call-1"); err != nil {
+		t.Fatalf("GetPendingCall() error = %v", err)
+	}
+	raw, _ := store.GetPendingCall(context.Background(), "call-1")
+	if raw == nil {
+		t.Fatal("expected Emit to persist a durable buffer entry for call-1")
+	}
+
+	agg.flush(context.Background(), "call-1", []*QoSMetrics{{ChannelID: "a-leg", CorrelationID: "call-1"}})
+
+	raw, _ = store.GetPendingCall(context.Background(), "call-1")
+	if raw != nil {
+		t.Error("expected flush to delete the durable buffer entry for call-1")
+	}
+}
+
+func TestCallAggregator_RestoreReloadsPendingCalls(t *testing.T) {
+	store := newFakePendingCallStore()
+	seed := NewCallAggregatorWithWindow(nil, time.Hour).withPersist(store)
+	seed.Emit(context.Background(), &QoSMetrics{ChannelID: "a-leg", CorrelationID: "call-2", MOSScore: 3.5, TotalPackets: 100})
+
+	var mu sync.Mutex
+	var reports []*CallQoSReport
+	restored := NewCallAggregatorWithWindow(nil, 10*time.Millisecond).withPersist(store)
+	restored.OnReport(func(report *CallQoSReport) {
+		mu.Lock()
+		defer mu.Unlock()
+		reports = append(reports, report)
+	})
+
+	if err := restored.Restore(context.Background()); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+
+	deadline := time.After(time.Second)
+	for {
+		mu.Lock()
+		n := len(reports)
+		mu.Unlock()
+		if n > 0 {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("timed out waiting for restored call to flush")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if reports[0].CorrelationID != "call-2" {
+		t.Errorf("CorrelationID = %q, want %q", reports[0].CorrelationID, "call-2")
+	}
+}
+
+func TestAggregateLegs_WeightedAvgAndWorstLegMOS(t *testing.T) {
+	legs := []*QoSMetrics{
+		{ChannelID: "a-leg", DomainName: "example.com", CodecName: "PCMU", MOSScore: 4.0, TotalPackets: 300, PacketLoss: 1, TotalBytes: 1000, MaxJitter: 5},
+		{ChannelID: "b-leg", DomainName: "example.com", CodecName: "PCMU", MOSScore: 2.0, TotalPackets: 100, PacketLoss: 10, TotalBytes: 500, MaxJitter: 20},
+	}
+
+	report := aggregateLegs("call-1", legs)
+
+	wantWeighted := (4.0*300 + 2.0*100) / 400
+	if report.WeightedAvgMOS != wantWeighted {
+		t.Errorf("WeightedAvgMOS = %v, want %v", report.WeightedAvgMOS, wantWeighted)
+	}
+	if report.WorstLegMOS != 2.0 {
+		t.Errorf("WorstLegMOS = %v, want 2.0", report.WorstLegMOS)
+	}
+	if report.TotalPacketLoss != 11 {
+		t.Errorf("TotalPacketLoss = %v, want 11", report.TotalPacketLoss)
+	}
+	if report.TotalPackets != 400 {
+		t.Errorf("TotalPackets = %v, want 400", report.TotalPackets)
+	}
+	if report.TotalBytes != 1500 {
+		t.Errorf("TotalBytes = %v, want 1500", report.TotalBytes)
+	}
+	if report.MaxJitter != 20 {
+		t.Errorf("MaxJitter = %v, want 20", report.MaxJitter)
+	}
+	if report.CodecMismatch {
+		t.Errorf("CodecMismatch = true, want false (both legs used PCMU)")
+	}
+	if len(report.Endpoints) != 2 {
+		t.Errorf("len(Endpoints) = %d, want 2", len(report.Endpoints))
+	}
+}
+
+func TestAggregateLegs_ZeroTrafficLegCountsUnweighted(t *testing.T) {
+	legs := []*QoSMetrics{
+		{ChannelID: "a-leg", CodecName: "PCMU", MOSScore: 4.0, TotalPackets: 0},
+		{ChannelID: "b-leg", CodecName: "PCMU", MOSScore: 2.0, TotalPackets: 0},
+	}
+
+	report := aggregateLegs("call-2", legs)
+
+	// Both legs have zero TotalPackets, so each falls back to weight 1: a
+	// plain average, not a divide-by-zero.
+	want := (4.0 + 2.0) / 2
+	if report.WeightedAvgMOS != want {
+		t.Errorf("WeightedAvgMOS = %v, want %v", report.WeightedAvgMOS, want)
+	}
+}
+
+func TestAggregateLegs_CodecMismatchDetected(t *testing.T) {
+	legs := []*QoSMetrics{
+		{ChannelID: "a-leg", CodecName: "PCMU", MOSScore: 4.0, TotalPackets: 100},
+		{ChannelID: "b-leg", CodecName: "OPUS", MOSScore: 4.0, TotalPackets: 100},
+	}
+
+	report := aggregateLegs("call-3", legs)
+
+	if !report.CodecMismatch {
+		t.Error("CodecMismatch = false, want true (legs used PCMU and OPUS)")
+	}
+}
+
+func TestAggregateLegs_SingleLeg(t *testing.T) {
+	legs := []*QoSMetrics{
+		{ChannelID: "a-leg", CodecName: "PCMU", MOSScore: 3.5, TotalPackets: 100, PacketLoss: 2, TotalBytes: 800, MaxJitter: 7},
+	}
+
+	report := aggregateLegs("call-4", legs)
+
+	if report.WeightedAvgMOS != 3.5 {
+		t.Errorf("WeightedAvgMOS = %v, want 3.5", report.WeightedAvgMOS)
+	}
+	if report.WorstLegMOS != 3.5 {
+		t.Errorf("WorstLegMOS = %v, want 3.5", report.WorstLegMOS)
+	}
+	if report.CodecMismatch {
+		t.Error("CodecMismatch = true, want false (only one leg)")
+	}
+}
@@ -0,0 +1,126 @@
+package calculator
+
+import (
+	"math"
+	"strings"
+)
+
+// codecImpairment holds the ITU-T G.113-style equipment impairment factor
+// (Ie) and packet-loss robustness factor (Bpl) used by the E-model for a
+// given codec. Values come from the appendix I tables commonly cited for
+// VoIP MOS estimation.
+type codecImpairment struct {
+	Ie  float64
+	Bpl float64
+	// latencyMs is the codec's own algorithmic + look-ahead delay, added to
+	// the network/jitter delay to get the total one-way mouth-to-ear delay.
+	latencyMs float64
+}
+
+var codecImpairments = map[string]codecImpairment{
+	"PCMU": {Ie: 0, Bpl: 4.3, latencyMs: 0},
+	"PCMA": {Ie: 0, Bpl: 4.3, latencyMs: 0},
+	"G729": {Ie: 11, Bpl: 19, latencyMs: 25},
+	"G722": {Ie: 4, Bpl: 8, latencyMs: 0},
+	"OPUS": {Ie: 5, Bpl: 10, latencyMs: 5},
+}
+
+// defaultCodecImpairment is used when CodecName doesn't match a known entry,
+// falling back to the (generous) PCMU/PCMA values rather than refusing to
+// estimate a MOS at all.
+var defaultCodecImpairment = codecImpairment{Ie: 0, Bpl: 4.3, latencyMs: 0}
+
+// eModelParams are the inputs to computeEModelMOS, derived from a single
+// CHANNEL_DESTROY event's or interim uuid_dump sample's extracted
+// quality/traffic/codec metrics.
+type eModelParams struct {
+	CodecName      string
+	PTimeMs        float64
+	AvgJitterMs    float64
+	NetworkDelayMs float64 // RTCP RTT/2 when available, else Delta (mean interval)
+	PacketLoss     int64
+	TotalPackets   int64
+	// BurstR is the E-model's loss-burstiness factor: the average number of
+	// consecutive packets lost per loss event. 1 means loss is random
+	// (Bernoulli), matching legacy callers that don't have loss-run data.
+	// Values above 1 mean loss arrives in longer bursts, which the E-model
+	// treats as more impairing (a lower MOS) than the same loss spread
+	// randomly across the call. See burstRatio for how this is derived.
+	BurstR float64
+}
+
+// computeEModelMOS derives a MOS estimate from a simplified ITU-T G.107
+// E-model, for use when FreeSWITCH's own `variable_rtp_audio_in_mos` is
+// missing or unreliable (e.g. bursty loss patterns FreeSWITCH's built-in
+// estimator doesn't model well).
+func computeEModelMOS(p eModelParams) float64 {
+	const r0 = 93.2
+
+	// FreeSWITCH's variable_rtp_use_codec_name case varies by build/module
+	// (observed lower-case "opus" as well as upper-case); codecImpairments
+	// keys are upper-case, so normalize before the lookup rather than
+	// silently falling back to PCMU's generous Ie/Bpl for a case mismatch.
+	impairment, ok := codecImpairments[strings.ToUpper(p.CodecName)]
+	if !ok {
+		impairment = defaultCodecImpairment
+	}
+
+	// Effective one-way delay: codec algorithmic delay + half the
+	// packetization interval + an adaptive jitter buffer sized at twice the
+	// observed average jitter + the estimated network one-way delay.
+	jitterBufferMs := 2 * p.AvgJitterMs
+	d := impairment.latencyMs + p.PTimeMs/2 + jitterBufferMs + p.NetworkDelayMs
+
+	// Delay impairment factor (ITU-T G.107 Id), with H the unit step function.
+	id := 0.024*d + 0.11*(d-177.3)*unitStep(d-177.3)
+
+	// Equipment impairment factor, degraded by packet loss using the
+	// codec's Bpl robustness factor.
+	ppl := 0.0
+	if p.TotalPackets > 0 {
+		ppl = 100 * float64(p.PacketLoss) / float64(p.TotalPackets)
+	}
+	burstR := p.BurstR
+	if burstR <= 0 {
+		burstR = 1
+	}
+	ieEff := impairment.Ie + (95-impairment.Ie)*ppl/(ppl/burstR+impairment.Bpl)
+
+	// Is (simultaneous impairment) and A (advantage factor) are both 0 for
+	// wired VoIP with no competing telephony-quality comparison.
+	const is = 0
+	const a = 0
+
+	r := r0 - is - id - ieEff + a
+
+	mos := 1 + 0.035*r + 7e-6*r*(r-60)*(100-r)
+	return clamp(mos, 1.0, 4.5)
+}
+
+// burstRatio estimates the E-model's BurstR from FreeSWITCH's flaw-total
+// counter: each flaw is one run of consecutive lost packets, so the average
+// burst length is packetLoss/flawTotal. Callers that can't report flawTotal
+// (e.g. a HeaderSource missing variable_rtp_audio_in_flaw_total) get 1,
+// which the E-model treats as purely random loss - the conservative
+// assumption when burstiness can't be measured.
+func burstRatio(packetLoss, flawTotal int64) float64 {
+	if flawTotal <= 0 || packetLoss <= 0 {
+		return 1
+	}
+	r := float64(packetLoss) / float64(flawTotal)
+	if r < 1 {
+		return 1
+	}
+	return r
+}
+
+func unitStep(x float64) float64 {
+	if x > 0 {
+		return 1
+	}
+	return 0
+}
+
+func clamp(v, min, max float64) float64 {
+	return math.Max(min, math.Min(max, v))
+}
@@ -0,0 +1,119 @@
+package calculator
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBurstRatio(t *testing.T) {
+	tests := []struct {
+		name       string
+		packetLoss int64
+		flawTotal  int64
+		want       float64
+	}{
+		{"no flaw data falls back to random loss", 50, 0, 1},
+		{"no loss", 0, 0, 1},
+		{"one flaw per lost packet is random loss", 10, 10, 1},
+		{"bursty loss averages several packets per flaw", 100, 10, 10},
+		{"flaw total exceeding loss count floors at 1", 5, 20, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := burstRatio(tt.packetLoss, tt.flawTotal); got != tt.want {
+				t.Errorf("burstRatio(%d, %d) = %v, want %v", tt.packetLoss, tt.flawTotal, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComputeEModelMOS_BurstyLossScoresLowerThanRandomLoss(t *testing.T) {
+	// A fixed BurstR=1 (what the formula silently collapsed to before BurstR
+	// was threaded through) treats loss as purely random no matter how it's
+	// actually distributed. The whole point of modeling BurstR is that the
+	// same Ppl concentrated into bursts (BurstR>1) is a worse listening
+	// experience, so it must score a lower (not equal, not higher) MOS than
+	// the same Ppl spread randomly.
+	base := eModelParams{
+		CodecName:      "PCMU",
+		PTimeMs:        20,
+		AvgJitterMs:    5,
+		NetworkDelayMs: 40,
+		PacketLoss:     20,
+		TotalPackets:   1000,
+	}
+
+	random := base
+	random.BurstR = 1
+	bursty := base
+	bursty.BurstR = 5
+
+	randomMOS := computeEModelMOS(random)
+	burstyMOS := computeEModelMOS(bursty)
+
+	if burstyMOS >= randomMOS {
+		t.Errorf("expected bursty loss (BurstR=%v) to score lower than random loss (BurstR=%v): got bursty=%v random=%v",
+			bursty.BurstR, random.BurstR, burstyMOS, randomMOS)
+	}
+}
+
+func TestComputeEModelMOS_ZeroBurstRDefaultsToRandomLoss(t *testing.T) {
+	withZero := eModelParams{
+		CodecName:      "PCMU",
+		PTimeMs:        20,
+		AvgJitterMs:    5,
+		NetworkDelayMs: 40,
+		PacketLoss:     20,
+		TotalPackets:   1000,
+		BurstR:         0,
+	}
+	withOne := withZero
+	withOne.BurstR = 1
+
+	got := computeEModelMOS(withZero)
+	want := computeEModelMOS(withOne)
+	if math.Abs(got-want) > 1e-9 {
+		t.Errorf("BurstR=0 should default to BurstR=1: got %v, want %v", got, want)
+	}
+}
+
+func TestComputeEModelMOS_CodecLookupIsCaseInsensitive(t *testing.T) {
+	// FreeSWITCH's variable_rtp_use_codec_name is reported in mixed/lower
+	// case as often as upper (e.g. "opus"); a case-sensitive lookup would
+	// silently fall back to PCMU's generous Ie/Bpl and over-estimate MOS.
+	base := eModelParams{
+		PTimeMs:        20,
+		AvgJitterMs:    5,
+		NetworkDelayMs: 40,
+		PacketLoss:     20,
+		TotalPackets:   1000,
+		BurstR:         1,
+	}
+
+	lower := base
+	lower.CodecName = "opus"
+	upper := base
+	upper.CodecName = "OPUS"
+
+	got := computeEModelMOS(lower)
+	want := computeEModelMOS(upper)
+	if math.Abs(got-want) > 1e-9 {
+		t.Errorf("codec name case should not affect MOS: CodecName=%q got %v, CodecName=%q want %v",
+			lower.CodecName, got, upper.CodecName, want)
+	}
+}
+
+func TestComputeEModelMOS_ClampedToValidRange(t *testing.T) {
+	mos := computeEModelMOS(eModelParams{
+		CodecName:      "G729",
+		PTimeMs:        20,
+		AvgJitterMs:    200,
+		NetworkDelayMs: 500,
+		PacketLoss:     900,
+		TotalPackets:   1000,
+		BurstR:         1,
+	})
+	if mos < 1.0 || mos > 4.5 {
+		t.Errorf("computeEModelMOS() = %v, want value clamped to [1.0, 4.5]", mos)
+	}
+}
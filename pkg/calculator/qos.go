@@ -8,6 +8,7 @@ import (
 
 	"github.com/luongdev/fsagent/pkg/connection"
 	"github.com/luongdev/fsagent/pkg/logger"
+	"github.com/luongdev/fsagent/pkg/metrics"
 	"github.com/luongdev/fsagent/pkg/store"
 )
 
@@ -17,6 +18,22 @@ type QoSCalculator interface {
 	CalculateMetrics(ctx context.Context, event *connection.FSEvent, instanceName string) (*QoSMetrics, error)
 }
 
+// HeaderSource abstracts over the places quality/traffic key-value data can
+// come from: a live CHANNEL_DESTROY event, or a parsed uuid_dump API
+// response polled mid-call by PeriodicSampler. Both expose the same
+// variable_rtp_audio_* style keys, so extractQualityMetrics and
+// extractTrafficMetrics only need this single method to serve both.
+type HeaderSource interface {
+	GetHeader(name string) string
+}
+
+// Kind distinguishes a final QoSMetrics, computed once at CHANNEL_DESTROY,
+// from an interim one sampled mid-call by PeriodicSampler.
+const (
+	KindFinal   = "final"
+	KindInterim = "interim"
+)
+
 // QoSMetrics represents calculated QoS metrics
 type QoSMetrics struct {
 	Timestamp     time.Time
@@ -24,19 +41,31 @@ type QoSMetrics struct {
 	ChannelID     string // Unique-ID for per-leg monitoring
 	CorrelationID string // SIP Call-ID for per-call aggregation
 	DomainName    string // SIP domain for filtering by tenant/domain
+	Kind          string // KindFinal or KindInterim
 
 	// Quality Metrics
-	MOSScore  float64
-	AvgJitter float64 // (min + max) / 2
-	MinJitter float64
-	MaxJitter float64
-	Delta     float64 // mean interval
+	MOSScore       float64 // effective MOS: RawMOSScore, or EModelMOSScore when UseEModel is set or FS reported none
+	RawMOSScore    float64 // as reported by FreeSWITCH's variable_rtp_audio_in_mos, 0 if absent
+	EModelMOSScore float64 // derived from the ITU-T G.107 E-model, 0 if not computed
+	AvgJitter      float64 // (min + max) / 2
+	MinJitter      float64
+	MaxJitter      float64
+	Delta          float64 // mean interval
+	FlawTotal      int64   // distinct quality-impairment events FreeSWITCH reported, 0 if not supported
 
 	// Traffic Metrics
 	TotalPackets int64 // in + out
 	PacketLoss   int64 // in + out skip packets
 	TotalBytes   int64 // in + out
 
+	// Directional traffic metrics, for W3C webrtc-stats serialization where
+	// inbound-rtp and outbound-rtp are reported as separate dictionaries.
+	InboundPackets    int64
+	InboundPacketLoss int64
+	InboundBytes      int64
+	OutboundPackets   int64
+	OutboundBytes     int64
+
 	// Codec Information
 	CodecName string
 	CodecPT   int
@@ -51,22 +80,76 @@ type QoSMetrics struct {
 
 	// Timing
 	ReportTimestamp int64
+
+	// RTP/RTCP identifiers, for correlating with browser-side WebRTC stats
+	Ssrc            uint32
+	RTCPRoundTripMs float64 // 0 if RTCP RTT wasn't reported
 }
 
+// calcLog is the package-scoped logger for calculator log lines that happen
+// outside a per-event context (and so can't use logger.FromContext), letting
+// operators bump just this package to DEBUG via /admin/log-level without
+// raising every other package's verbosity too.
+var calcLog = logger.ForPackage("calculator")
+
 // qosCalculator implements QoSCalculator interface
 type qosCalculator struct {
-	store store.StateStore
+	store      store.StateStore
+	useEModel  bool
+	fanout     *sinkFanout
+	aggregator *CallAggregator
+}
+
+// Option configures optional qosCalculator behavior.
+type Option func(*qosCalculator)
+
+// WithEModel enables deriving MOS from the ITU-T G.107 E-model for every
+// call, not just when FreeSWITCH's own variable_rtp_audio_in_mos is absent.
+// Useful for deployments where FreeSWITCH's built-in estimate is known to be
+// unreliable (e.g. bursty loss).
+func WithEModel(enabled bool) Option {
+	return func(qc *qosCalculator) {
+		qc.useEModel = enabled
+	}
+}
+
+// WithSinks publishes every successfully calculated QoSMetrics to each given
+// Sink, fanned out over bounded per-sink queues so a slow backend can't
+// block event processing.
+func WithSinks(sinks ...Sink) Option {
+	return func(qc *qosCalculator) {
+		qc.fanout = newSinkFanout(sinks)
+	}
+}
+
+// WithCallAggregator feeds every successfully calculated QoSMetrics into agg,
+// so A-leg/B-leg pairs get correlated into a single CallQoSReport instead of
+// downstream consumers only ever seeing two uncorrelated per-channel reports.
+func WithCallAggregator(agg *CallAggregator) Option {
+	return func(qc *qosCalculator) {
+		qc.aggregator = agg
+	}
 }
 
 // NewQoSCalculator creates a new QoS calculator
-func NewQoSCalculator(store store.StateStore) QoSCalculator {
-	return &qosCalculator{
+func NewQoSCalculator(store store.StateStore, opts ...Option) QoSCalculator {
+	qc := &qosCalculator{
 		store: store,
 	}
+	for _, opt := range opts {
+		opt(qc)
+	}
+	return qc
 }
 
 // CalculateMetrics processes CHANNEL_DESTROY event and returns QoS metrics
 func (qc *qosCalculator) CalculateMetrics(ctx context.Context, event *connection.FSEvent, instanceName string) (*QoSMetrics, error) {
+	// Bound ahead of the metrics local var below, which would otherwise
+	// shadow the pkg/metrics package import for the rest of this function.
+	recordLatency := metrics.GetMetrics().ObserveEventProcessingLatency
+	start := time.Now()
+	defer func() { recordLatency(instanceName, "CHANNEL_DESTROY", time.Since(start).Seconds()) }()
+
 	// Check for variable_rtp_use_codec_rate presence - only process if exists
 	if event.GetHeader("variable_rtp_use_codec_rate") == "" {
 		return nil, fmt.Errorf("variable_rtp_use_codec_rate not present, skipping QoS calculation")
@@ -82,83 +165,89 @@ func (qc *qosCalculator) CalculateMetrics(ctx context.Context, event *connection
 		Timestamp:    time.Now(),
 		InstanceName: instanceName,
 		ChannelID:    channelID,
+		Kind:         KindFinal,
 	}
 
+	// Seed the context with correlation fields so every log call below, and
+	// any further down the call chain, is automatically tagged for per-call
+	// trace reconstruction in log aggregators.
+	ctx = logger.WithContext(ctx, map[string]interface{}{
+		"channel_id": channelID,
+		"instance":   instanceName,
+	})
+	clog := logger.FromContext(ctx)
+
 	// Extract MOS score
 	if err := qc.extractQualityMetrics(event, metrics); err != nil {
-		logger.ErrorWithFields(map[string]interface{}{
-			"channel_id":  channelID,
-			"fs_instance": instanceName,
-			"error":       err.Error(),
-		}, "Failed to extract quality metrics")
+		clog.Error("Failed to extract quality metrics: %v", err)
 		return nil, fmt.Errorf("failed to extract quality metrics: %w", err)
 	}
 
 	// Extract traffic metrics
 	if err := qc.extractTrafficMetrics(event, metrics); err != nil {
-		logger.ErrorWithFields(map[string]interface{}{
-			"channel_id":  channelID,
-			"fs_instance": instanceName,
-			"error":       err.Error(),
-		}, "Failed to extract traffic metrics")
+		clog.Error("Failed to extract traffic metrics: %v", err)
 		return nil, fmt.Errorf("failed to extract traffic metrics: %w", err)
 	}
 
 	// Extract codec information
 	if err := qc.extractCodecInfo(event, metrics); err != nil {
-		logger.ErrorWithFields(map[string]interface{}{
-			"channel_id":  channelID,
-			"fs_instance": instanceName,
-			"error":       err.Error(),
-		}, "Failed to extract codec info")
+		clog.Error("Failed to extract codec info: %v", err)
 		return nil, fmt.Errorf("failed to extract codec info: %w", err)
 	}
 
+	// Resolve the effective MOS score, falling back to (or preferring, if
+	// UseEModel is set) the E-model estimate now that jitter, loss, and codec
+	// info are all available.
+	qc.resolveMOSScore(metrics)
+
 	// Retrieve correlation_id and domain_name from state, or extract from event
 	if err := qc.extractStateAndDomain(ctx, event, metrics); err != nil {
-		logger.ErrorWithFields(map[string]interface{}{
-			"channel_id":  channelID,
-			"fs_instance": instanceName,
-			"error":       err.Error(),
-		}, "Failed to extract state and domain")
+		clog.Error("Failed to extract state and domain: %v", err)
 		return nil, fmt.Errorf("failed to extract state and domain: %w", err)
 	}
 
-	logger.DebugWithFields(map[string]interface{}{
+	// Re-scope now that correlation_id and domain_name are known, so the
+	// success log line below (and anything logged further downstream with
+	// this ctx) carries the full correlation set.
+	ctx = logger.WithContext(ctx, map[string]interface{}{
 		"channel_id":     channelID,
 		"correlation_id": metrics.CorrelationID,
-		"fs_instance":    instanceName,
-		"mos_score":      metrics.MOSScore,
-		"avg_jitter_ms":  metrics.AvgJitter,
-		"min_jitter_ms":  metrics.MinJitter,
-		"max_jitter_ms":  metrics.MaxJitter,
-		"delta_ms":       metrics.Delta,
-		"packet_loss":    metrics.PacketLoss,
-		"total_packets":  metrics.TotalPackets,
-		"codec_name":     metrics.CodecName,
-	}, "QoS metrics calculated successfully")
+		"instance":       instanceName,
+		"domain":         metrics.DomainName,
+	})
+	clog = logger.FromContext(ctx)
+
+	clog.Debug("QoS metrics calculated successfully: mos=%.2f avg_jitter_ms=%.2f min_jitter_ms=%.2f max_jitter_ms=%.2f delta_ms=%.2f packet_loss=%d total_packets=%d codec=%s",
+		metrics.MOSScore, metrics.AvgJitter, metrics.MinJitter, metrics.MaxJitter, metrics.Delta, metrics.PacketLoss, metrics.TotalPackets, metrics.CodecName)
+
+	if qc.fanout != nil {
+		qc.fanout.publish(metrics)
+	}
+	if qc.aggregator != nil {
+		qc.aggregator.Emit(ctx, metrics)
+	}
 
 	return metrics, nil
 }
 
 // extractQualityMetrics extracts MOS score and jitter metrics
-func (qc *qosCalculator) extractQualityMetrics(event *connection.FSEvent, metrics *QoSMetrics) error {
-	// Extract MOS score from variable_rtp_audio_in_mos
-	if mosStr := event.GetHeader("variable_rtp_audio_in_mos"); mosStr != "" {
+func (qc *qosCalculator) extractQualityMetrics(source HeaderSource, metrics *QoSMetrics) error {
+	// Extract MOS score from variable_rtp_audio_in_mos, if FreeSWITCH reported one
+	if mosStr := source.GetHeader("variable_rtp_audio_in_mos"); mosStr != "" {
 		if mos, err := strconv.ParseFloat(mosStr, 64); err == nil {
-			metrics.MOSScore = mos
+			metrics.RawMOSScore = mos
 		}
 	}
 
 	// Extract min jitter variance
-	if minJitterStr := event.GetHeader("variable_rtp_audio_in_jitter_min_variance"); minJitterStr != "" {
+	if minJitterStr := source.GetHeader("variable_rtp_audio_in_jitter_min_variance"); minJitterStr != "" {
 		if minJitter, err := strconv.ParseFloat(minJitterStr, 64); err == nil {
 			metrics.MinJitter = minJitter
 		}
 	}
 
 	// Extract max jitter variance
-	if maxJitterStr := event.GetHeader("variable_rtp_audio_in_jitter_max_variance"); maxJitterStr != "" {
+	if maxJitterStr := source.GetHeader("variable_rtp_audio_in_jitter_max_variance"); maxJitterStr != "" {
 		if maxJitter, err := strconv.ParseFloat(maxJitterStr, 64); err == nil {
 			metrics.MaxJitter = maxJitter
 		}
@@ -170,30 +259,39 @@ func (qc *qosCalculator) extractQualityMetrics(event *connection.FSEvent, metric
 	}
 
 	// Extract delta (mean interval)
-	if deltaStr := event.GetHeader("variable_rtp_audio_in_mean_interval"); deltaStr != "" {
+	if deltaStr := source.GetHeader("variable_rtp_audio_in_mean_interval"); deltaStr != "" {
 		if delta, err := strconv.ParseFloat(deltaStr, 64); err == nil {
 			metrics.Delta = delta
 		}
 	}
 
+	// Extract flaw total: FreeSWITCH's count of distinct quality-impairment
+	// events (bursts of consecutive loss count as one flaw each), used to
+	// estimate the E-model's BurstR loss-burstiness factor.
+	if flawStr := source.GetHeader("variable_rtp_audio_in_flaw_total"); flawStr != "" {
+		if flaws, err := strconv.ParseInt(flawStr, 10, 64); err == nil {
+			metrics.FlawTotal = flaws
+		}
+	}
+
 	return nil
 }
 
 // extractTrafficMetrics sums inbound and outbound traffic metrics
-func (qc *qosCalculator) extractTrafficMetrics(event *connection.FSEvent, metrics *QoSMetrics) error {
+func (qc *qosCalculator) extractTrafficMetrics(source HeaderSource, metrics *QoSMetrics) error {
 	var inboundPackets, outboundPackets int64
 	var inboundBytes, outboundBytes int64
 	var inboundSkip, outboundSkip int64
 
 	// Extract inbound packet count
-	if inPacketsStr := event.GetHeader("variable_rtp_audio_in_packet_count"); inPacketsStr != "" {
+	if inPacketsStr := source.GetHeader("variable_rtp_audio_in_packet_count"); inPacketsStr != "" {
 		if packets, err := strconv.ParseInt(inPacketsStr, 10, 64); err == nil {
 			inboundPackets = packets
 		}
 	}
 
 	// Extract outbound packet count
-	if outPacketsStr := event.GetHeader("variable_rtp_audio_out_packet_count"); outPacketsStr != "" {
+	if outPacketsStr := source.GetHeader("variable_rtp_audio_out_packet_count"); outPacketsStr != "" {
 		if packets, err := strconv.ParseInt(outPacketsStr, 10, 64); err == nil {
 			outboundPackets = packets
 		}
@@ -201,16 +299,18 @@ func (qc *qosCalculator) extractTrafficMetrics(event *connection.FSEvent, metric
 
 	// Sum total packets
 	metrics.TotalPackets = inboundPackets + outboundPackets
+	metrics.InboundPackets = inboundPackets
+	metrics.OutboundPackets = outboundPackets
 
 	// Extract inbound byte count (media bytes)
-	if inBytesStr := event.GetHeader("variable_rtp_audio_in_media_bytes"); inBytesStr != "" {
+	if inBytesStr := source.GetHeader("variable_rtp_audio_in_media_bytes"); inBytesStr != "" {
 		if bytes, err := strconv.ParseInt(inBytesStr, 10, 64); err == nil {
 			inboundBytes = bytes
 		}
 	}
 
 	// Extract outbound byte count (media bytes)
-	if outBytesStr := event.GetHeader("variable_rtp_audio_out_media_bytes"); outBytesStr != "" {
+	if outBytesStr := source.GetHeader("variable_rtp_audio_out_media_bytes"); outBytesStr != "" {
 		if bytes, err := strconv.ParseInt(outBytesStr, 10, 64); err == nil {
 			outboundBytes = bytes
 		}
@@ -218,16 +318,18 @@ func (qc *qosCalculator) extractTrafficMetrics(event *connection.FSEvent, metric
 
 	// Sum total bytes
 	metrics.TotalBytes = inboundBytes + outboundBytes
+	metrics.InboundBytes = inboundBytes
+	metrics.OutboundBytes = outboundBytes
 
 	// Extract inbound skip packet count (packet loss)
-	if inSkipStr := event.GetHeader("variable_rtp_audio_in_skip_packet_count"); inSkipStr != "" {
+	if inSkipStr := source.GetHeader("variable_rtp_audio_in_skip_packet_count"); inSkipStr != "" {
 		if skip, err := strconv.ParseInt(inSkipStr, 10, 64); err == nil {
 			inboundSkip = skip
 		}
 	}
 
 	// Extract outbound skip packet count (packet loss)
-	if outSkipStr := event.GetHeader("variable_rtp_audio_out_skip_packet_count"); outSkipStr != "" {
+	if outSkipStr := source.GetHeader("variable_rtp_audio_out_skip_packet_count"); outSkipStr != "" {
 		if skip, err := strconv.ParseInt(outSkipStr, 10, 64); err == nil {
 			outboundSkip = skip
 		}
@@ -235,70 +337,114 @@ func (qc *qosCalculator) extractTrafficMetrics(event *connection.FSEvent, metric
 
 	// Sum total packet loss
 	metrics.PacketLoss = inboundSkip + outboundSkip
+	metrics.InboundPacketLoss = inboundSkip
 
 	return nil
 }
 
 // extractCodecInfo extracts codec information and media endpoints
-func (qc *qosCalculator) extractCodecInfo(event *connection.FSEvent, metrics *QoSMetrics) error {
+func (qc *qosCalculator) extractCodecInfo(source HeaderSource, metrics *QoSMetrics) error {
 	// Extract codec name
-	if codecName := event.GetHeader("variable_rtp_use_codec_name"); codecName != "" {
+	if codecName := source.GetHeader("variable_rtp_use_codec_name"); codecName != "" {
 		metrics.CodecName = codecName
 	}
 
 	// Extract codec payload type
-	if codecPTStr := event.GetHeader("variable_rtp_use_codec_pt"); codecPTStr != "" {
+	if codecPTStr := source.GetHeader("variable_rtp_use_codec_pt"); codecPTStr != "" {
 		if pt, err := strconv.Atoi(codecPTStr); err == nil {
 			metrics.CodecPT = pt
 		}
 	}
 
 	// Extract ptime (packetization time)
-	if ptimeStr := event.GetHeader("variable_rtp_use_codec_ptime"); ptimeStr != "" {
+	if ptimeStr := source.GetHeader("variable_rtp_use_codec_ptime"); ptimeStr != "" {
 		if ptime, err := strconv.Atoi(ptimeStr); err == nil {
 			metrics.PTime = ptime
 		}
 	}
 
 	// Extract clock rate
-	if clockRateStr := event.GetHeader("variable_rtp_use_codec_rate"); clockRateStr != "" {
+	if clockRateStr := source.GetHeader("variable_rtp_use_codec_rate"); clockRateStr != "" {
 		if rate, err := strconv.Atoi(clockRateStr); err == nil {
 			metrics.ClockRate = rate
 		}
 	}
 
 	// Extract local media IP and port
-	if localIP := event.GetHeader("variable_local_media_ip"); localIP != "" {
+	if localIP := source.GetHeader("variable_local_media_ip"); localIP != "" {
 		metrics.SrcIP = localIP
 	}
 
-	if localPortStr := event.GetHeader("variable_local_media_port"); localPortStr != "" {
+	if localPortStr := source.GetHeader("variable_local_media_port"); localPortStr != "" {
 		if port, err := strconv.ParseUint(localPortStr, 10, 16); err == nil {
 			metrics.SrcPort = uint16(port)
 		}
 	}
 
 	// Extract remote media IP and port
-	if remoteIP := event.GetHeader("variable_remote_media_ip"); remoteIP != "" {
+	if remoteIP := source.GetHeader("variable_remote_media_ip"); remoteIP != "" {
 		metrics.DstIP = remoteIP
 	}
 
-	if remotePortStr := event.GetHeader("variable_remote_media_port"); remotePortStr != "" {
+	if remotePortStr := source.GetHeader("variable_remote_media_port"); remotePortStr != "" {
 		if port, err := strconv.ParseUint(remotePortStr, 10, 16); err == nil {
 			metrics.DstPort = uint16(port)
 		}
 	}
 
 	// Extract report timestamp
-	if timestampStr := event.GetHeader("Event-Date-Timestamp"); timestampStr != "" {
+	if timestampStr := source.GetHeader("Event-Date-Timestamp"); timestampStr != "" {
 		if timestamp, err := strconv.ParseInt(timestampStr, 10, 64); err == nil {
 			metrics.ReportTimestamp = timestamp
 		}
 	}
 
+	// Extract RTP SSRC
+	if ssrcStr := source.GetHeader("variable_rtp_use_ssrc"); ssrcStr != "" {
+		if ssrc, err := strconv.ParseUint(ssrcStr, 10, 32); err == nil {
+			metrics.Ssrc = uint32(ssrc)
+		}
+	}
+
+	// Extract RTCP round-trip time
+	if rttStr := source.GetHeader("variable_rtp_audio_rtcp_rtt"); rttStr != "" {
+		if rtt, err := strconv.ParseFloat(rttStr, 64); err == nil {
+			metrics.RTCPRoundTripMs = rtt
+		}
+	}
+
 	return nil
 }
 
+// resolveMOSScore picks the effective MOSScore: the FreeSWITCH-reported
+// value, unless it's missing or UseEModel was requested, in which case an
+// E-model estimate is derived from jitter, loss, and one-way delay and
+// recorded on EModelMOSScore alongside it.
+func (qc *qosCalculator) resolveMOSScore(metrics *QoSMetrics) {
+	if !qc.useEModel && metrics.RawMOSScore > 0 {
+		metrics.MOSScore = metrics.RawMOSScore
+		return
+	}
+
+	// Prefer half the RTCP round-trip time as the network one-way delay
+	// estimate; fall back to the mean interval when RTT wasn't reported.
+	networkDelayMs := metrics.Delta
+	if metrics.RTCPRoundTripMs > 0 {
+		networkDelayMs = metrics.RTCPRoundTripMs / 2
+	}
+
+	metrics.EModelMOSScore = computeEModelMOS(eModelParams{
+		CodecName:      metrics.CodecName,
+		PTimeMs:        float64(metrics.PTime),
+		AvgJitterMs:    metrics.AvgJitter,
+		NetworkDelayMs: networkDelayMs,
+		PacketLoss:     metrics.PacketLoss,
+		TotalPackets:   metrics.TotalPackets,
+		BurstR:         burstRatio(metrics.PacketLoss, metrics.FlawTotal),
+	})
+	metrics.MOSScore = metrics.EModelMOSScore
+}
+
 // extractStateAndDomain retrieves correlation_id and domain_name from state or event
 func (qc *qosCalculator) extractStateAndDomain(ctx context.Context, event *connection.FSEvent, metrics *QoSMetrics) error {
 	channelID := metrics.ChannelID
@@ -313,11 +459,7 @@ func (qc *qosCalculator) extractStateAndDomain(ctx context.Context, event *conne
 		// Delete channel state after metrics calculation
 		if delErr := qc.store.Delete(ctx, channelID); delErr != nil {
 			// Log warning but don't fail - metrics are already calculated
-			logger.WarnWithFields(map[string]interface{}{
-				"channel_id":     channelID,
-				"correlation_id": metrics.CorrelationID,
-				"error":          delErr.Error(),
-			}, "Failed to delete channel state after QoS calculation")
+			logger.FromContext(ctx).Warn("Failed to delete channel state after QoS calculation: correlation_id=%s error=%v", metrics.CorrelationID, delErr)
 		}
 
 		return nil
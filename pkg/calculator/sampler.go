@@ -0,0 +1,270 @@
+package calculator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luongdev/fsagent/pkg/store"
+)
+
+// DefaultSampleInterval is how often PeriodicSampler polls each active
+// channel when no interval is configured.
+const DefaultSampleInterval = 10 * time.Second
+
+// FSApiClient issues a FreeSWITCH API command (e.g. uuid_dump) against a
+// specific instance and returns its raw text response. Implemented by
+// connection's connection manager.
+type FSApiClient interface {
+	SendAPI(ctx context.Context, instanceName, command string) (string, error)
+}
+
+// channelLister is the subset of store.StateStore PeriodicSampler needs
+// beyond the Get/Delete already used elsewhere in this package: a way to
+// enumerate every channel currently tracked, so each tick can poll every
+// active call without the sampler duplicating that bookkeeping itself.
+type channelLister interface {
+	store.StateStore
+	ActiveChannelIDs(ctx context.Context) ([]string, error)
+}
+
+// uuidDumpResponse wraps a parsed `uuid_dump` API response so it can be fed
+// through extractQualityMetrics/extractTrafficMetrics/extractCodecInfo the
+// same way a CHANNEL_DESTROY event is: uuid_dump emits the same
+// variable_rtp_audio_* keys, one "name: value" pair per line.
+type uuidDumpResponse struct {
+	headers map[string]string
+}
+
+func parseUUIDDump(raw string) *uuidDumpResponse {
+	headers := make(map[string]string)
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			continue
+		}
+		headers[name] = value
+	}
+	return &uuidDumpResponse{headers: headers}
+}
+
+func (r *uuidDumpResponse) GetHeader(name string) string {
+	return r.headers[name]
+}
+
+// cumulativeSample is the running totals PeriodicSampler last saw for a
+// channel, so the next tick can report per-interval deltas instead of the
+// all-time cumulative values FreeSWITCH reports.
+type cumulativeSample struct {
+	totalPackets int64
+	packetLoss   int64
+	totalBytes   int64
+	flawTotal    int64
+}
+
+// PeriodicSampler polls every active channel known to the state store on a
+// fixed interval, issuing uuid_dump against FreeSWITCH and producing interim
+// QoSMetrics. This gives long calls quality visibility, and lets operators
+// alert, before the channel tears down and CalculateMetrics runs.
+type PeriodicSampler struct {
+	lister   channelLister
+	api      FSApiClient
+	qos      *qosCalculator
+	interval time.Duration
+
+	mu        sync.Mutex
+	baselines map[string]cumulativeSample
+
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// SamplerOption configures optional PeriodicSampler behavior.
+type SamplerOption func(*PeriodicSampler)
+
+// WithSampleInterval overrides DefaultSampleInterval.
+func WithSampleInterval(interval time.Duration) SamplerOption {
+	return func(s *PeriodicSampler) {
+		s.interval = interval
+	}
+}
+
+// NewPeriodicSampler creates a sampler that polls st for active channels and
+// api to read their live RTP stats. qc must be the *qosCalculator produced
+// by NewQoSCalculator, since PeriodicSampler reuses its private extraction
+// and MOS-resolution logic to keep interim and final metrics consistent.
+func NewPeriodicSampler(st store.StateStore, api FSApiClient, qc QoSCalculator, opts ...SamplerOption) (*PeriodicSampler, error) {
+	lister, ok := st.(channelLister)
+	if !ok {
+		return nil, fmt.Errorf("store.StateStore does not implement ActiveChannelIDs, required for mid-call sampling")
+	}
+	inner, ok := qc.(*qosCalculator)
+	if !ok {
+		return nil, fmt.Errorf("PeriodicSampler requires the QoSCalculator returned by NewQoSCalculator")
+	}
+
+	s := &PeriodicSampler{
+		lister:    lister,
+		api:       api,
+		qos:       inner,
+		interval:  DefaultSampleInterval,
+		baselines: make(map[string]cumulativeSample),
+		stopCh:    make(chan struct{}),
+		doneCh:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+// Start begins polling in the background until Stop is called or ctx is
+// cancelled.
+func (s *PeriodicSampler) Start(ctx context.Context) {
+	go s.run(ctx)
+}
+
+// Stop ends the polling loop and waits for the in-flight tick, if any, to
+// finish.
+func (s *PeriodicSampler) Stop() error {
+	close(s.stopCh)
+	<-s.doneCh
+	return nil
+}
+
+func (s *PeriodicSampler) run(ctx context.Context) {
+	defer close(s.doneCh)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.sampleAll(ctx)
+		case <-s.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *PeriodicSampler) sampleAll(ctx context.Context) {
+	channelIDs, err := s.lister.ActiveChannelIDs(ctx)
+	if err != nil {
+		calcLog.Error("PeriodicSampler failed to list active channels: %v", err)
+		return
+	}
+
+	for _, channelID := range channelIDs {
+		s.sampleOne(ctx, channelID)
+	}
+}
+
+func (s *PeriodicSampler) sampleOne(ctx context.Context, channelID string) {
+	state, err := s.lister.Get(ctx, channelID)
+	if err != nil {
+		// Channel tore down between listing and polling; CHANNEL_DESTROY
+		// already handled (or will handle) its final report. Evict any
+		// baseline kept for it here, since the CHANNEL_DESTROY path has no
+		// reference back to this sampler to call ForgetChannel itself -
+		// otherwise baselines grows by one entry per channel for the life
+		// of the process.
+		s.ForgetChannel(channelID)
+		return
+	}
+
+	raw, err := s.api.SendAPI(ctx, state.InstanceName, fmt.Sprintf("uuid_dump %s", channelID))
+	if err != nil {
+		calcLog.Warn("PeriodicSampler failed to poll channel: channel_id=%s error=%v", channelID, err)
+		return
+	}
+
+	source := parseUUIDDump(raw)
+	if source.GetHeader("variable_rtp_use_codec_rate") == "" {
+		// Media hasn't been established on this leg yet.
+		return
+	}
+
+	metrics := &QoSMetrics{
+		Timestamp:     time.Now(),
+		InstanceName:  state.InstanceName,
+		ChannelID:     channelID,
+		CorrelationID: state.CorrelationID,
+		DomainName:    state.DomainName,
+		Kind:          KindInterim,
+	}
+
+	if err := s.qos.extractQualityMetrics(source, metrics); err != nil {
+		calcLog.Warn("PeriodicSampler failed to extract quality metrics: channel_id=%s error=%v", channelID, err)
+		return
+	}
+	if err := s.qos.extractTrafficMetrics(source, metrics); err != nil {
+		calcLog.Warn("PeriodicSampler failed to extract traffic metrics: channel_id=%s error=%v", channelID, err)
+		return
+	}
+	if err := s.qos.extractCodecInfo(source, metrics); err != nil {
+		calcLog.Warn("PeriodicSampler failed to extract codec info: channel_id=%s error=%v", channelID, err)
+		return
+	}
+
+	s.applyDelta(channelID, metrics)
+	s.qos.resolveMOSScore(metrics)
+
+	calcLog.Debug("Interim QoS metrics sampled: channel_id=%s mos=%.2f avg_jitter_ms=%.2f packet_loss=%d total_packets=%d",
+		metrics.ChannelID, metrics.MOSScore, metrics.AvgJitter, metrics.PacketLoss, metrics.TotalPackets)
+
+	if s.qos.fanout != nil {
+		s.qos.fanout.publish(metrics)
+	}
+}
+
+// applyDelta rewrites metrics' cumulative TotalPackets/PacketLoss/TotalBytes/
+// FlawTotal (as FreeSWITCH reports them, since call start) into per-interval
+// deltas against the last sample taken for this channel, then records the
+// new cumulative totals as the baseline for next time. The first sample for
+// a channel has no baseline, so it reports the cumulative totals as-is.
+//
+// FlawTotal must be delta-adjusted alongside PacketLoss: resolveMOSScore
+// derives BurstR from burstRatio(PacketLoss, FlawTotal), and comparing a
+// delta-adjusted PacketLoss against a still-cumulative FlawTotal would skew
+// that ratio for every interim sample after the first.
+func (s *PeriodicSampler) applyDelta(channelID string, metrics *QoSMetrics) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := cumulativeSample{
+		totalPackets: metrics.TotalPackets,
+		packetLoss:   metrics.PacketLoss,
+		totalBytes:   metrics.TotalBytes,
+		flawTotal:    metrics.FlawTotal,
+	}
+
+	if baseline, ok := s.baselines[channelID]; ok {
+		metrics.TotalPackets = current.totalPackets - baseline.totalPackets
+		metrics.PacketLoss = current.packetLoss - baseline.packetLoss
+		metrics.TotalBytes = current.totalBytes - baseline.totalBytes
+		metrics.FlawTotal = current.flawTotal - baseline.flawTotal
+	}
+
+	s.baselines[channelID] = current
+}
+
+// ForgetChannel drops any baseline kept for channelID, so a channel ID reused
+// later doesn't start from a stale baseline. sampleOne already calls this
+// once it notices a channel is gone (its state Get fails), so callers that
+// run CalculateMetrics for the same channel don't strictly need to call this
+// themselves, though doing so right after the final report is emitted frees
+// the entry sooner than waiting for the next poll tick to notice.
+func (s *PeriodicSampler) ForgetChannel(channelID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.baselines, channelID)
+}
@@ -0,0 +1,85 @@
+package calculator
+
+import (
+	"context"
+)
+
+// Sink publishes a single channel's QoS metrics to an external backend (a
+// time-series database, a local file, a metrics endpoint, etc.). It lives in
+// the calculator package itself, rather than pkg/calculator/sink, because
+// its method signature references QoSMetrics: putting the interface in the
+// sink subpackage alongside the concrete implementations that also need
+// QoSMetrics would create an import cycle (calculator -> sink -> calculator).
+// Concrete sinks live under pkg/calculator/sink and import calculator for
+// this type instead.
+type Sink interface {
+	Publish(ctx context.Context, metrics *QoSMetrics) error
+}
+
+// defaultSinkQueueSize bounds how many pending metrics a slow sink can
+// accumulate before new publishes start dropping the oldest queued one.
+const defaultSinkQueueSize = 256
+
+// sinkWorker fans a single sink out onto its own bounded queue so one slow
+// backend can't block event processing or the other sinks.
+type sinkWorker struct {
+	sink  Sink
+	queue chan *QoSMetrics
+}
+
+func newSinkWorker(sink Sink) *sinkWorker {
+	w := &sinkWorker{
+		sink:  sink,
+		queue: make(chan *QoSMetrics, defaultSinkQueueSize),
+	}
+	go w.run()
+	return w
+}
+
+func (w *sinkWorker) run() {
+	for metrics := range w.queue {
+		if err := w.sink.Publish(context.Background(), metrics); err != nil {
+			calcLog.Error("Sink publish failed: channel_id=%s error=%v", metrics.ChannelID, err)
+		}
+	}
+}
+
+// enqueue drops the oldest queued item to make room when the queue is full,
+// rather than blocking the caller (the event-processing path).
+func (w *sinkWorker) enqueue(metrics *QoSMetrics) {
+	select {
+	case w.queue <- metrics:
+		return
+	default:
+	}
+
+	select {
+	case <-w.queue:
+	default:
+	}
+
+	select {
+	case w.queue <- metrics:
+	default:
+	}
+}
+
+// sinkFanout publishes to every configured sink concurrently via per-sink
+// bounded queues.
+type sinkFanout struct {
+	workers []*sinkWorker
+}
+
+func newSinkFanout(sinks []Sink) *sinkFanout {
+	workers := make([]*sinkWorker, len(sinks))
+	for i, sink := range sinks {
+		workers[i] = newSinkWorker(sink)
+	}
+	return &sinkFanout{workers: workers}
+}
+
+func (f *sinkFanout) publish(metrics *QoSMetrics) {
+	for _, w := range f.workers {
+		w.enqueue(metrics)
+	}
+}
@@ -0,0 +1,114 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/luongdev/fsagent/pkg/calculator"
+)
+
+// InfluxSink serializes QoSMetrics as InfluxDB/Telegraf line protocol and
+// ships it over UDP (Telegraf's socket_listener input) or HTTP (InfluxDB's
+// /write API).
+type InfluxSink struct {
+	// Network is "udp" or "http". HTTP requires WriteURL; UDP requires Addr.
+	Network string
+	Addr    string // host:port, for Network == "udp"
+	// WriteURL is the full InfluxDB write endpoint, for Network == "http",
+	// e.g. "http://localhost:8086/write?db=fsagent".
+	WriteURL string
+
+	httpClient *http.Client
+	udpConn    net.Conn
+}
+
+// NewInfluxUDPSink ships line protocol to a Telegraf socket_listener at addr.
+func NewInfluxUDPSink(addr string) (*InfluxSink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial influx UDP sink at %s: %w", addr, err)
+	}
+	return &InfluxSink{Network: "udp", Addr: addr, udpConn: conn}, nil
+}
+
+// NewInfluxHTTPSink writes line protocol to an InfluxDB /write endpoint.
+func NewInfluxHTTPSink(writeURL string) *InfluxSink {
+	return &InfluxSink{
+		Network:    "http",
+		WriteURL:   writeURL,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Publish writes metrics as a single line-protocol point.
+func (s *InfluxSink) Publish(ctx context.Context, metrics *calculator.QoSMetrics) error {
+	line := s.toLineProtocol(metrics)
+
+	switch s.Network {
+	case "udp":
+		_, err := s.udpConn.Write([]byte(line))
+		return err
+	case "http":
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.WriteURL, bytes.NewBufferString(line))
+		if err != nil {
+			return err
+		}
+		resp, err := s.httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("influx write returned status %d", resp.StatusCode)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown influx sink network %q", s.Network)
+	}
+}
+
+// toLineProtocol renders: fs_qos,instance=...,codec=...,domain=... mos=...,jitter_avg=...,packet_loss=...i ... <timestamp>
+func (s *InfluxSink) toLineProtocol(m *calculator.QoSMetrics) string {
+	tags := fmt.Sprintf("fs_qos,instance=%s,codec=%s,domain=%s",
+		escapeTag(m.InstanceName), escapeTag(m.CodecName), escapeTag(m.DomainName))
+
+	fields := fmt.Sprintf("mos=%.2f,jitter_avg=%.2f,jitter_min=%.2f,jitter_max=%.2f",
+		m.MOSScore, m.AvgJitter, m.MinJitter, m.MaxJitter)
+
+	// TotalPackets/PacketLoss/TotalBytes are cumulative since call start on a
+	// final report, but per-interval deltas on an interim one (PeriodicSampler
+	// polls mid-call and rebases against its last sample - see applyDelta).
+	// Writing both as points on the same series would double-count whatever a
+	// downstream query sums across a call's lifetime, so only the final
+	// report carries them.
+	if m.Kind == calculator.KindFinal {
+		fields += fmt.Sprintf(",packet_loss=%di,total_packets=%di,total_bytes=%di",
+			m.PacketLoss, m.TotalPackets, m.TotalBytes)
+	}
+
+	// Interim metrics come from uuid_dump, whose response has no
+	// Event-Date-Timestamp header, so ReportTimestamp is never populated for
+	// them. Fall back to when the sample was taken rather than writing every
+	// interim point at the Unix epoch.
+	timestampNs := m.ReportTimestamp * int64(time.Microsecond)
+	if m.ReportTimestamp == 0 {
+		ts := m.Timestamp
+		if ts.IsZero() {
+			ts = time.Now()
+		}
+		timestampNs = ts.UnixNano()
+	}
+	return fmt.Sprintf("%s %s %d\n", tags, fields, timestampNs)
+}
+
+// escapeTag escapes characters InfluxDB line protocol treats as tag
+// delimiters (space, comma, equals).
+func escapeTag(value string) string {
+	replacer := strings.NewReplacer(" ", "\\ ", ",", "\\,", "=", "\\=")
+	return replacer.Replace(value)
+}
@@ -0,0 +1,57 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/luongdev/fsagent/pkg/calculator"
+)
+
+// JSONLinesSink appends one JSON-encoded QoSMetrics object per line to a
+// writer, e.g. a log-rotated file or stdout for local debugging.
+type JSONLinesSink struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+// NewJSONLinesSink writes to an arbitrary writer (e.g. os.Stdout).
+func NewJSONLinesSink(w io.Writer) *JSONLinesSink {
+	return &JSONLinesSink{w: w}
+}
+
+// NewJSONLinesFileSink opens path for appending and writes JSON lines to it.
+// Call Close when done to release the file handle.
+func NewJSONLinesFileSink(path string) (*JSONLinesSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open JSON-lines sink file %s: %w", path, err)
+	}
+	return &JSONLinesSink{w: f, closer: f}, nil
+}
+
+// Publish marshals metrics and writes it as a single newline-terminated line.
+func (s *JSONLinesSink) Publish(ctx context.Context, metrics *calculator.QoSMetrics) error {
+	data, err := json.Marshal(metrics)
+	if err != nil {
+		return fmt.Errorf("failed to marshal QoS metrics: %w", err)
+	}
+	data = append(data, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(data)
+	return err
+}
+
+// Close releases the underlying file handle, if this sink owns one.
+func (s *JSONLinesSink) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
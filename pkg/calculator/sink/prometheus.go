@@ -0,0 +1,84 @@
+package sink
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/luongdev/fsagent/pkg/calculator"
+)
+
+// PrometheusSink exposes per-codec/per-domain MOS and jitter distributions,
+// plus packet/loss counters, on its own registry so it can be mounted at a
+// dedicated scrape endpoint independent of the process-wide /metrics served
+// by pkg/metrics.
+type PrometheusSink struct {
+	registry *prometheus.Registry
+
+	mos        *prometheus.HistogramVec
+	jitter     *prometheus.HistogramVec
+	packets    *prometheus.CounterVec
+	packetLoss *prometheus.CounterVec
+}
+
+// NewPrometheusSink creates a sink with its own registry, ready to mount via
+// Handler().
+func NewPrometheusSink() *PrometheusSink {
+	s := &PrometheusSink{
+		registry: prometheus.NewRegistry(),
+		mos: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "fsagent_qos_mos",
+			Help:    "Distribution of per-call MOS scores",
+			Buckets: []float64{1.0, 1.5, 2.0, 2.5, 3.0, 3.5, 3.8, 4.0, 4.2, 4.5},
+		}, []string{"codec", "domain"}),
+		jitter: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "fsagent_qos_jitter_ms",
+			Help:    "Distribution of average jitter, in milliseconds",
+			Buckets: prometheus.ExponentialBuckets(1, 2, 10),
+		}, []string{"codec", "domain"}),
+		packets: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "fsagent_qos_packets_total",
+			Help: "Total packets observed across calculated calls",
+		}, []string{"codec", "domain"}),
+		packetLoss: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "fsagent_qos_packet_loss_total",
+			Help: "Total lost packets observed across calculated calls",
+		}, []string{"codec", "domain"}),
+	}
+
+	s.registry.MustRegister(s.mos, s.jitter, s.packets, s.packetLoss)
+	return s
+}
+
+// Publish records metrics against the codec/domain label pair.
+func (s *PrometheusSink) Publish(ctx context.Context, metrics *calculator.QoSMetrics) error {
+	codec := metrics.CodecName
+	if codec == "" {
+		codec = "unknown"
+	}
+	domain := metrics.DomainName
+	if domain == "" {
+		domain = "unknown"
+	}
+
+	s.mos.WithLabelValues(codec, domain).Observe(metrics.MOSScore)
+	s.jitter.WithLabelValues(codec, domain).Observe(metrics.AvgJitter)
+
+	// TotalPackets/PacketLoss are cumulative since call start on a final
+	// report, but per-interval deltas on an interim one (PeriodicSampler polls
+	// mid-call and rebases against its last sample - see applyDelta). Adding
+	// both into the same counter would double-count: the final report alone
+	// already covers the whole call.
+	if metrics.Kind == calculator.KindFinal {
+		s.packets.WithLabelValues(codec, domain).Add(float64(metrics.TotalPackets))
+		s.packetLoss.WithLabelValues(codec, domain).Add(float64(metrics.PacketLoss))
+	}
+	return nil
+}
+
+// Handler returns an http.Handler that scrapes this sink's registry.
+func (s *PrometheusSink) Handler() http.Handler {
+	return promhttp.HandlerFor(s.registry, promhttp.HandlerOpts{})
+}
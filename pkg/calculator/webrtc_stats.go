@@ -0,0 +1,82 @@
+package calculator
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// webrtcStatsDoc is the top-level document returned by MarshalWebRTCStats,
+// mirroring the shape of RTCStatsReport.toJSON() in the browser: a flat
+// array of stats dictionaries, each tagged with its own "type".
+type webrtcStatsDoc struct {
+	Stats []map[string]interface{} `json:"stats"`
+}
+
+// MarshalWebRTCStats renders m as a W3C webrtc-stats compatible JSON
+// document, borrowing the inbound-rtp/outbound-rtp/remote-inbound-rtp/codec
+// dictionary shapes. This lets browser-side dashboards and existing
+// webrtc-stats tooling diff SIP-side numbers against browser WebRTC numbers
+// on hybrid calls.
+func (m *QoSMetrics) MarshalWebRTCStats() ([]byte, error) {
+	timestampMs := m.ReportTimestamp / 1000
+	codecID := fmt.Sprintf("CODEC_%s_%d", m.CodecName, m.CodecPT)
+	mediaSourceID := fmt.Sprintf("SourceAudio_%s", m.ChannelID)
+
+	inboundRTP := map[string]interface{}{
+		"id":              fmt.Sprintf("RTPInboundAudio_%s", m.ChannelID),
+		"timestamp":       timestampMs,
+		"type":            "inbound-rtp",
+		"kind":            "audio",
+		"ssrc":            m.Ssrc,
+		"packetsReceived": m.InboundPackets,
+		"packetsLost":     m.InboundPacketLoss,
+		"jitter":          m.AvgJitter / 1000.0, // ms -> seconds
+		"bytesReceived":   m.InboundBytes,
+		"codecId":         codecID,
+	}
+
+	outboundRTP := map[string]interface{}{
+		"id":            fmt.Sprintf("RTPOutboundAudio_%s", m.ChannelID),
+		"timestamp":     timestampMs,
+		"type":          "outbound-rtp",
+		"kind":          "audio",
+		"ssrc":          m.Ssrc,
+		"packetsSent":   m.OutboundPackets,
+		"bytesSent":     m.OutboundBytes,
+		"codecId":       codecID,
+		"mediaSourceId": mediaSourceID,
+	}
+
+	mediaSource := map[string]interface{}{
+		"id":              mediaSourceID,
+		"timestamp":       timestampMs,
+		"type":            "media-source",
+		"kind":            "audio",
+		"trackIdentifier": m.ChannelID,
+	}
+
+	codec := map[string]interface{}{
+		"id":          codecID,
+		"timestamp":   timestampMs,
+		"type":        "codec",
+		"payloadType": m.CodecPT,
+		"mimeType":    fmt.Sprintf("audio/%s", m.CodecName),
+		"clockRate":   m.ClockRate,
+	}
+
+	stats := []map[string]interface{}{inboundRTP, outboundRTP, mediaSource, codec}
+
+	if m.RTCPRoundTripMs > 0 {
+		stats = append(stats, map[string]interface{}{
+			"id":            fmt.Sprintf("RTCPRemoteInboundAudio_%s", m.ChannelID),
+			"timestamp":     timestampMs,
+			"type":          "remote-inbound-rtp",
+			"kind":          "audio",
+			"ssrc":          m.Ssrc,
+			"codecId":       codecID,
+			"roundTripTime": m.RTCPRoundTripMs / 1000.0, // ms -> seconds
+		})
+	}
+
+	return json.Marshal(webrtcStatsDoc{Stats: stats})
+}
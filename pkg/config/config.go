@@ -0,0 +1,133 @@
+// Package config loads FSAgent's YAML configuration file into typed structs
+// used throughout cmd/fsagent to wire up storage, connections, telemetry,
+// and logging.
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the root of FSAgent's configuration file.
+type Config struct {
+	FreeSwitchInstances []FreeSwitchInstance `yaml:"freeswitch_instances"`
+	Storage             StorageConfig        `yaml:"storage"`
+	HTTP                HTTPConfig           `yaml:"http"`
+	OpenTelemetry       OpenTelemetryConfig  `yaml:"opentelemetry"`
+	Events              EventsConfig         `yaml:"events"`
+	Logging             LoggingConfig        `yaml:"logging"`
+	Calculator          CalculatorConfig     `yaml:"calculator"`
+}
+
+// FreeSwitchInstance is one FreeSWITCH event-socket endpoint FSAgent connects to.
+type FreeSwitchInstance struct {
+	Name     string `yaml:"name"`
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Password string `yaml:"password"`
+}
+
+// StorageConfig selects and configures the channel state store.
+type StorageConfig struct {
+	Type  string       `yaml:"type"` // "memory" or "redis"
+	Redis *RedisConfig `yaml:"redis,omitempty"`
+}
+
+// RedisConfig holds connection details for the Redis-backed state store.
+type RedisConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Password string `yaml:"password"`
+	DB       int    `yaml:"db"`
+}
+
+// HTTPConfig configures the admin/metrics/health HTTP server.
+type HTTPConfig struct {
+	Port int `yaml:"port"`
+}
+
+// OpenTelemetryConfig configures the OTLP metrics exporter.
+type OpenTelemetryConfig struct {
+	Endpoint string `yaml:"endpoint"`
+	Insecure bool   `yaml:"insecure"`
+}
+
+// EventsConfig toggles which FreeSWITCH event-derived calculations run.
+type EventsConfig struct {
+	RTCP bool `yaml:"rtcp"`
+	QoS  bool `yaml:"qos"`
+}
+
+// LoggingConfig configures the logger package: level, output format,
+// per-package level overrides applied at startup (and re-applied on
+// SIGHUP), and the output destinations log lines are written to.
+type LoggingConfig struct {
+	Level    string            `yaml:"level"`
+	Format   string            `yaml:"format"`
+	Packages map[string]string `yaml:"packages,omitempty"`
+	Sinks    []LogSinkConfig   `yaml:"sinks,omitempty"`
+}
+
+// LogSinkConfig describes one entry under logging.sinks, mirroring
+// logger.SinkConfig's fields so main.go can adapt one to the other without
+// the logger package depending on the config package.
+type LogSinkConfig struct {
+	Type string `yaml:"type"` // "stdout", "file", or "syslog"
+
+	// File sink options.
+	Path       string `yaml:"path,omitempty"`
+	MaxSizeMB  int    `yaml:"max_size_mb,omitempty"`
+	MaxAgeDays int    `yaml:"max_age_days,omitempty"`
+	MaxBackups int    `yaml:"max_backups,omitempty"`
+	Compress   bool   `yaml:"compress,omitempty"`
+
+	// Syslog sink options.
+	Network string `yaml:"network,omitempty"`
+	Address string `yaml:"address,omitempty"`
+	Tag     string `yaml:"tag,omitempty"`
+}
+
+// CalculatorConfig configures the QoS calculator.
+type CalculatorConfig struct {
+	// UseEModel derives MOS from the ITU-T G.107 E-model for every call,
+	// not just when FreeSWITCH's own variable_rtp_audio_in_mos is absent.
+	UseEModel bool `yaml:"use_emodel"`
+
+	// Sinks are the metrics backends each calculated QoSMetrics is published
+	// to, in addition to being returned to the caller.
+	Sinks []MetricsSinkConfig `yaml:"sinks,omitempty"`
+}
+
+// MetricsSinkConfig describes one entry under calculator.sinks. Only the
+// fields relevant to Type need to be set.
+type MetricsSinkConfig struct {
+	Type string `yaml:"type"` // "influx_udp", "influx_http", "prometheus", or "jsonlines"
+
+	// influx_udp's host:port.
+	Address string `yaml:"address,omitempty"`
+	// influx_http.
+	WriteURL string `yaml:"write_url,omitempty"`
+	// prometheus' mount path, and jsonlines' output file (stdout if empty).
+	Path string `yaml:"path,omitempty"`
+}
+
+// Load reads and parses the YAML configuration file at path.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	cfg := &Config{
+		Storage: StorageConfig{Type: "memory"},
+		HTTP:    HTTPConfig{Port: 8080},
+		Logging: LoggingConfig{Level: "info", Format: "json"},
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,139 @@
+// Package health runs startup and liveness/readiness probes for FSAgent's
+// external dependencies (state store, OTLP collector, FreeSWITCH instances),
+// replacing the one-shot "connect once and discard" validation that used to
+// live in main.go with retrying, continuously-reported checks.
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/luongdev/fsagent/pkg/logger"
+)
+
+// Probe is a single named dependency check (e.g. "redis", "otlp").
+type Probe struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// BackoffConfig controls how RunWithBackoff retries a failing probe before
+// giving up.
+type BackoffConfig struct {
+	Initial time.Duration
+	Max     time.Duration
+	Total   time.Duration
+}
+
+// DefaultBackoff doubles the retry interval from 500ms up to 8s, giving up
+// after 60s total so a momentarily-unreachable Redis or OTLP collector
+// during a rolling deploy doesn't fail the whole startup.
+var DefaultBackoff = BackoffConfig{
+	Initial: 500 * time.Millisecond,
+	Max:     8 * time.Second,
+	Total:   60 * time.Second,
+}
+
+// Checker runs startup probes with backoff and tracks a live readiness flag
+// that continuous probes (e.g. FreeSWITCH connection status) can flip.
+type Checker struct {
+	probes []Probe
+
+	mu    sync.RWMutex
+	ready bool
+}
+
+// NewChecker creates an empty Checker. Readiness defaults to false until the
+// first successful RunWithBackoff call or an explicit SetReady(true).
+func NewChecker() *Checker {
+	return &Checker{}
+}
+
+// Register adds a startup probe to the checker.
+func (c *Checker) Register(probe Probe) {
+	c.probes = append(c.probes, probe)
+}
+
+// RunWithBackoff runs every registered probe, retrying each one with
+// exponential backoff (per cfg) before failing startup. On success it marks
+// the checker ready.
+func (c *Checker) RunWithBackoff(ctx context.Context, cfg BackoffConfig) error {
+	for _, probe := range c.probes {
+		if err := runProbeWithBackoff(ctx, probe, cfg); err != nil {
+			return fmt.Errorf("probe %q failed after retrying: %w", probe.Name, err)
+		}
+		logger.Info("Health probe %q succeeded", probe.Name)
+	}
+	c.SetReady(true)
+	return nil
+}
+
+func runProbeWithBackoff(ctx context.Context, probe Probe, cfg BackoffConfig) error {
+	deadline := time.Now().Add(cfg.Total)
+	delay := cfg.Initial
+	var lastErr error
+
+	for attempt := 1; time.Now().Before(deadline); attempt++ {
+		if err := probe.Check(ctx); err == nil {
+			return nil
+		} else {
+			lastErr = err
+			logger.Warn("Health probe %q attempt %d failed: %v", probe.Name, attempt, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > cfg.Max {
+			delay = cfg.Max
+		}
+	}
+	return lastErr
+}
+
+// SetReady flips the readiness flag exposed by ReadinessHandler. Continuous
+// probes (e.g. a FreeSWITCH connection-status watcher) call this as
+// connections come and go, independent of the one-time startup probes.
+func (c *Checker) SetReady(ready bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ready = ready
+}
+
+// Ready reports the current readiness state.
+func (c *Checker) Ready() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ready
+}
+
+// LivenessHandler serves /healthz: 200 as long as the process is up and
+// responding, regardless of dependency state.
+func (c *Checker) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok"))
+	})
+}
+
+// ReadinessHandler serves /readyz: 200 when Ready(), 503 otherwise, so a
+// Kubernetes load balancer pulls the pod from rotation without FSAgent being
+// killed and restarted.
+func (c *Checker) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.Ready() {
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("ready"))
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("not ready"))
+	})
+}
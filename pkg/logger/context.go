@@ -0,0 +1,75 @@
+package logger
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+)
+
+type contextKey struct{}
+
+var loggerContextKey = contextKey{}
+
+// WithContext returns a copy of ctx carrying a zerolog.Logger pre-populated
+// with fields (typically channel_id, correlation_id, instance, and domain).
+// Logging through the *Logger returned by FromContext(ctx) automatically
+// includes these fields, enabling per-call trace reconstruction in log
+// aggregators without threading field maps through every function call.
+func WithContext(ctx context.Context, fields map[string]interface{}) context.Context {
+	if defaultLogger == nil {
+		Init(INFO)
+	}
+
+	defaultLogger.mu.RLock()
+	zctx := defaultLogger.zlogger.With()
+	defaultLogger.mu.RUnlock()
+
+	for key, value := range fields {
+		zctx = zctx.Interface(key, value)
+	}
+
+	// The scoped logger has no package name of its own to look up in
+	// PackageLevels (WithContext seeds call/channel fields, not a package),
+	// so it inherits defaultLogger's already-resolved level and zlogger
+	// (which, per refreshZerologLevel, already floors at the most verbose
+	// configured package override) rather than carrying a second, unused
+	// copy of the override map.
+	scoped := &Logger{
+		level:   GetLevel(),
+		format:  defaultLogger.format,
+		zlogger: zctx.Logger(),
+	}
+	return context.WithValue(ctx, loggerContextKey, scoped)
+}
+
+// FromContext returns the Logger attached to ctx by WithContext, or the
+// default logger if ctx carries none.
+func FromContext(ctx context.Context) *Logger {
+	if logger, ok := ctx.Value(loggerContextKey).(*Logger); ok {
+		return logger
+	}
+	if defaultLogger == nil {
+		Init(INFO)
+	}
+	return defaultLogger
+}
+
+// Debug logs a debug message scoped to this logger's context fields.
+func (l *Logger) Debug(format string, args ...interface{}) { l.log(DEBUG, format, args...) }
+
+// Info logs an info message scoped to this logger's context fields.
+func (l *Logger) Info(format string, args ...interface{}) { l.log(INFO, format, args...) }
+
+// Warn logs a warning message scoped to this logger's context fields.
+func (l *Logger) Warn(format string, args ...interface{}) { l.log(WARN, format, args...) }
+
+// Error logs an error message scoped to this logger's context fields.
+func (l *Logger) Error(format string, args ...interface{}) { l.log(ERROR, format, args...) }
+
+// Zerolog returns the underlying zerolog.Logger for callers that need direct
+// access (e.g. to attach one-off fields without widening the context scope).
+func (l *Logger) Zerolog() zerolog.Logger {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.zlogger
+}
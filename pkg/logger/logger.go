@@ -1,8 +1,10 @@
 package logger
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"strings"
 	"sync"
@@ -59,10 +61,11 @@ func ParseLogLevel(level string) LogLevel {
 
 // Logger is a structured logger with log levels
 type Logger struct {
-	level   LogLevel
-	format  string
-	zlogger zerolog.Logger
-	mu      sync.RWMutex
+	level    LogLevel
+	format   string
+	zlogger  zerolog.Logger
+	packages map[string]LogLevel // per-package level overrides
+	mu       sync.RWMutex
 }
 
 var (
@@ -82,6 +85,33 @@ func InitWithFormat(level LogLevel, format string) {
 	})
 }
 
+// InitWithSinks initializes the default logger writing to the given sinks
+// (stdout, file, syslog) instead of the os.Stdout default. Only the first of
+// this, InitWithFormat, or Init takes effect; a later call to this one still
+// builds its sinks and returns any error from doing so, but leaves the
+// already-initialized default logger unchanged.
+func InitWithSinks(level LogLevel, format string, sinks []SinkConfig) error {
+	writers, err := buildWriters(sinks)
+	if err != nil {
+		return err
+	}
+
+	var output io.Writer
+	switch len(writers) {
+	case 0:
+		output = os.Stdout
+	case 1:
+		output = writers[0]
+	default:
+		output = zerolog.MultiLevelWriter(writers...)
+	}
+
+	once.Do(func() {
+		defaultLogger = newLogger(level, format, output)
+	})
+	return nil
+}
+
 // newLogger creates a new logger instance
 func newLogger(level LogLevel, format string, output io.Writer) *Logger {
 	// Configure zerolog based on format
@@ -103,9 +133,10 @@ func newLogger(level LogLevel, format string, output io.Writer) *Logger {
 	zlogger = zlogger.Level(toZerologLevel(level))
 
 	return &Logger{
-		level:   level,
-		format:  format,
-		zlogger: zlogger,
+		level:    level,
+		format:   format,
+		zlogger:  zlogger,
+		packages: make(map[string]LogLevel),
 	}
 }
 
@@ -133,7 +164,7 @@ func SetLevel(level LogLevel) {
 	defaultLogger.mu.Lock()
 	defer defaultLogger.mu.Unlock()
 	defaultLogger.level = level
-	defaultLogger.zlogger = defaultLogger.zlogger.Level(toZerologLevel(level))
+	defaultLogger.refreshZerologLevel()
 }
 
 // GetLevel returns the current log level
@@ -146,8 +177,205 @@ func GetLevel() LogLevel {
 	return defaultLogger.level
 }
 
-// log writes a log message if the level is enabled
+// SetPackageLevel overrides the log level for a single package (e.g. "connection",
+// "processor") without affecting the global level. An empty pkg is stored as an
+// override of its own; it does not clear the other overrides.
+func SetPackageLevel(pkg string, level LogLevel) {
+	if defaultLogger == nil {
+		Init(INFO)
+	}
+	defaultLogger.mu.Lock()
+	defer defaultLogger.mu.Unlock()
+	defaultLogger.packages[pkg] = level
+	defaultLogger.refreshZerologLevel()
+}
+
+// ClearPackageLevel removes the per-package override for pkg, falling back to the
+// global level.
+func ClearPackageLevel(pkg string) {
+	if defaultLogger == nil {
+		Init(INFO)
+	}
+	defaultLogger.mu.Lock()
+	defer defaultLogger.mu.Unlock()
+	delete(defaultLogger.packages, pkg)
+	defaultLogger.refreshZerologLevel()
+}
+
+// PackageLevels returns a snapshot of the current per-package overrides.
+func PackageLevels() map[string]LogLevel {
+	if defaultLogger == nil {
+		Init(INFO)
+	}
+	defaultLogger.mu.RLock()
+	defer defaultLogger.mu.RUnlock()
+
+	levels := make(map[string]LogLevel, len(defaultLogger.packages))
+	for pkg, level := range defaultLogger.packages {
+		levels[pkg] = level
+	}
+	return levels
+}
+
+// effectiveLevel returns the level that should gate a log call originating from pkg,
+// falling back to the global level when there is no override.
+func effectiveLevel(pkg string) LogLevel {
+	if defaultLogger == nil {
+		Init(INFO)
+	}
+	defaultLogger.mu.RLock()
+	defer defaultLogger.mu.RUnlock()
+	if level, ok := defaultLogger.packages[pkg]; ok {
+		return level
+	}
+	return defaultLogger.level
+}
+
+// PackageLogger scopes log calls to a package name so a per-package level
+// override (set via SetPackageLevel or the /admin/log-level endpoint) is
+// honored without raising the global level.
+type PackageLogger struct {
+	pkg string
+}
+
+// ForPackage returns a logger scoped to pkg. Packages that want independently
+// tunable verbosity (e.g. "connection", "processor") should log through this
+// instead of the package-level Debug/Info/Warn/Error functions.
+func ForPackage(pkg string) *PackageLogger {
+	return &PackageLogger{pkg: pkg}
+}
+
+func (p *PackageLogger) log(level LogLevel, format string, args ...interface{}) {
+	if level < effectiveLevel(p.pkg) {
+		return
+	}
+	if defaultLogger == nil {
+		Init(INFO)
+	}
+	// The effectiveLevel check above is this call's gate, already accounting
+	// for p.pkg's override; logDirect writes without re-gating against the
+	// global level, since defaultLogger.log would otherwise drop a message a
+	// package override raised to DEBUG while the global level stays INFO.
+	defaultLogger.logDirect(level, format, args...)
+}
+
+// Debug logs a debug message gated by this package's effective level.
+func (p *PackageLogger) Debug(format string, args ...interface{}) { p.log(DEBUG, format, args...) }
+
+// Info logs an info message gated by this package's effective level.
+func (p *PackageLogger) Info(format string, args ...interface{}) { p.log(INFO, format, args...) }
+
+// Warn logs a warning message gated by this package's effective level.
+func (p *PackageLogger) Warn(format string, args ...interface{}) { p.log(WARN, format, args...) }
+
+// Error logs an error message gated by this package's effective level.
+func (p *PackageLogger) Error(format string, args ...interface{}) { p.log(ERROR, format, args...) }
+
+// logLevelRequest is the body accepted by the admin log-level endpoint.
+type logLevelRequest struct {
+	Level   string `json:"level"`
+	Package string `json:"package,omitempty"`
+}
+
+// logLevelResponse is returned by the admin log-level endpoint.
+type logLevelResponse struct {
+	Level    string            `json:"level"`
+	Packages map[string]string `json:"packages,omitempty"`
+}
+
+// AdminLogLevelHandler returns an http.Handler suitable for mounting at
+// /admin/log-level on the HTTP server. GET reports the current global level
+// plus any per-package overrides; PUT/POST accepts {"level":"debug"} to change
+// the global level, or {"level":"debug","package":"connection"} to override a
+// single package.
+func AdminLogLevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			packages := make(map[string]string)
+			for pkg, level := range PackageLevels() {
+				packages[pkg] = level.String()
+			}
+			writeJSON(w, http.StatusOK, logLevelResponse{
+				Level:    GetLevel().String(),
+				Packages: packages,
+			})
+		case http.MethodPut, http.MethodPost:
+			var req logLevelRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+				return
+			}
+			if req.Level == "" {
+				http.Error(w, "level is required", http.StatusBadRequest)
+				return
+			}
+			level := ParseLogLevel(req.Level)
+			if req.Package != "" {
+				SetPackageLevel(req.Package, level)
+				Info("Log level for package %s changed to %s via admin endpoint", req.Package, level)
+			} else {
+				SetLevel(level)
+				Info("Global log level changed to %s via admin endpoint", level)
+			}
+			writeJSON(w, http.StatusOK, logLevelResponse{Level: GetLevel().String()})
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// ApplyPackageLevels seeds per-package overrides at startup (or on SIGHUP
+// reload) from a config-driven map, e.g. logging.packages: {connection: debug}.
+func ApplyPackageLevels(packages map[string]string) {
+	for pkg, levelStr := range packages {
+		SetPackageLevel(pkg, ParseLogLevel(levelStr))
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// refreshZerologLevel re-gates the underlying zlogger at the most verbose
+// (lowest) of the global level and any per-package override. zerolog applies
+// its own level check inside zlogger.Debug()/Info()/etc before a message
+// ever reaches logDirect, so without this a package override raised to
+// DEBUG while the global level stays INFO would be silently dropped by
+// zerolog itself, never mind the explicit l.level/effectiveLevel checks
+// above. Callers must hold l.mu.
+func (l *Logger) refreshZerologLevel() {
+	floor := l.level
+	for _, pkgLevel := range l.packages {
+		if pkgLevel < floor {
+			floor = pkgLevel
+		}
+	}
+	l.zlogger = l.zlogger.Level(toZerologLevel(floor))
+}
+
+// log writes a log message if the level is enabled against the global level.
+// Package-scoped calls that already checked effectiveLevel should use
+// logDirect instead, so a per-package override isn't re-gated against the
+// (possibly stricter) global level here.
 func (l *Logger) log(level LogLevel, format string, args ...interface{}) {
+	l.mu.RLock()
+	gate := l.level
+	l.mu.RUnlock()
+
+	if level < gate {
+		return
+	}
+	l.logDirect(level, format, args...)
+}
+
+// logDirect writes a log message without any level gate, on the assumption
+// the caller (log, or PackageLogger.log via effectiveLevel) already decided
+// it should be emitted.
+func (l *Logger) logDirect(level LogLevel, format string, args ...interface{}) {
 	l.mu.RLock()
 	zlogger := l.zlogger
 	l.mu.RUnlock()
@@ -196,6 +424,74 @@ func (l *Logger) logWithFields(level LogLevel, fields map[string]interface{}, fo
 	event.Msg(message)
 }
 
+// shutdownHooks are run, in registration order, by Fatal before the process
+// exits so that partially-initialized resources (state store, exporter, etc.)
+// get a chance to close cleanly.
+var (
+	shutdownHooks   []func()
+	shutdownHooksMu sync.Mutex
+)
+
+// RegisterShutdownHook appends fn to the chain run by Fatal prior to os.Exit.
+// Hooks run in registration order and are expected to be quick and not panic.
+func RegisterShutdownHook(fn func()) {
+	shutdownHooksMu.Lock()
+	defer shutdownHooksMu.Unlock()
+	shutdownHooks = append(shutdownHooks, fn)
+}
+
+func runShutdownHooks() {
+	shutdownHooksMu.Lock()
+	hooks := make([]func(), len(shutdownHooks))
+	copy(hooks, shutdownHooks)
+	shutdownHooksMu.Unlock()
+
+	for _, hook := range hooks {
+		hook()
+	}
+}
+
+// Fatal logs at zerolog's FatalLevel, runs the registered shutdown hook chain
+// so deferred cleanup still executes, then exits the process with status 1.
+// Unlike zerolog's native Fatal (which exits inside Msg, before a caller gets
+// a chance to run anything), the hooks are run before the message is
+// written so they still get to execute ahead of the same os.Exit(1).
+func Fatal(format string, args ...interface{}) {
+	if defaultLogger == nil {
+		Init(INFO)
+	}
+	message := fmt.Sprintf(format, args...)
+	runShutdownHooks()
+
+	defaultLogger.mu.RLock()
+	zlogger := defaultLogger.zlogger
+	defaultLogger.mu.RUnlock()
+	zlogger.Fatal().Msg(message)
+}
+
+// Fatalf is an alias for Fatal.
+func Fatalf(format string, args ...interface{}) {
+	Fatal(format, args...)
+}
+
+// Panic logs at zerolog's PanicLevel describing an unrecoverable
+// initialization failure, runs the shutdown hook chain, then panics with the
+// same message so the runtime stack trace is preserved for crash
+// diagnostics. The hook chain runs before zlogger.Panic().Msg triggers the
+// panic, for the same reason Fatal runs it before exiting.
+func Panic(format string, args ...interface{}) {
+	if defaultLogger == nil {
+		Init(INFO)
+	}
+	message := fmt.Sprintf(format, args...)
+	runShutdownHooks()
+
+	defaultLogger.mu.RLock()
+	zlogger := defaultLogger.zlogger
+	defaultLogger.mu.RUnlock()
+	zlogger.Panic().Msg(message)
+}
+
 // Debug logs a debug message
 func Debug(format string, args ...interface{}) {
 	if defaultLogger == nil {
@@ -1,20 +1,26 @@
 package metrics
 
 import (
-	"fmt"
+	"strings"
 	"sync"
-	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
-// InternalMetrics tracks internal application metrics
+// InternalMetrics tracks internal application metrics as native Prometheus
+// collectors, registered alongside Go runtime metrics on a single /metrics
+// endpoint.
 type InternalMetrics struct {
-	eventsReceived        map[string]map[string]*int64 // instance -> event_type -> count
-	eventsProcessed       map[string]map[string]*int64 // instance -> event_type -> count
-	rtcpMessagesProcessed map[string]map[string]*int64 // instance -> direction -> count
-	qosMessagesGenerated  map[string]*int64            // instance -> count
-	storageOperations     map[string]map[string]*int64 // operation -> status -> count
-	fsConnections         map[string]*int64            // instance -> status (1=connected, 0=disconnected)
-	mu                    sync.RWMutex
+	eventsReceived         *prometheus.CounterVec
+	eventsProcessed        *prometheus.CounterVec
+	eventProcessingLatency *prometheus.HistogramVec
+	rtcpMessagesProcessed  *prometheus.CounterVec
+	qosMessagesGenerated   *prometheus.CounterVec
+	storageOperations      *prometheus.CounterVec
+	fsConnections          *prometheus.GaugeVec
+
+	mu         sync.Mutex
+	registered bool
 }
 
 var (
@@ -26,171 +32,118 @@ var (
 func GetMetrics() *InternalMetrics {
 	once.Do(func() {
 		globalMetrics = &InternalMetrics{
-			eventsReceived:        make(map[string]map[string]*int64),
-			eventsProcessed:       make(map[string]map[string]*int64),
-			rtcpMessagesProcessed: make(map[string]map[string]*int64),
-			qosMessagesGenerated:  make(map[string]*int64),
-			storageOperations:     make(map[string]map[string]*int64),
-			fsConnections:         make(map[string]*int64),
+			eventsReceived: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "fsagent_events_received_total",
+				Help: "Total number of events received from FreeSWITCH",
+			}, []string{"instance", "event_type"}),
+			eventsProcessed: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "fsagent_events_processed_total",
+				Help: "Total number of events processed successfully",
+			}, []string{"instance", "event_type"}),
+			eventProcessingLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+				Name:    "fsagent_event_processing_latency_seconds",
+				Help:    "Time spent processing a single event, from dispatch to its final QoS metrics being available",
+				Buckets: prometheus.ExponentialBuckets(0.001, 2, 12),
+			}, []string{"instance", "event_type"}),
+			rtcpMessagesProcessed: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "fsagent_rtcp_messages_processed_total",
+				Help: "Total number of RTCP messages processed",
+			}, []string{"instance", "direction"}),
+			qosMessagesGenerated: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "fsagent_qos_messages_generated_total",
+				Help: "Total number of QoS messages generated",
+			}, []string{"instance"}),
+			storageOperations: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "fsagent_storage_operations_total",
+				Help: "Total number of storage operations",
+			}, []string{"operation", "status"}),
+			fsConnections: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+				Name: "fsagent_fs_connections",
+				Help: "FreeSWITCH connection status (1=connected, 0=disconnected)",
+			}, []string{"instance"}),
 		}
 	})
 	return globalMetrics
 }
 
-// IncrementEventsReceived increments the events received counter
-func (m *InternalMetrics) IncrementEventsReceived(instance, eventType string) {
+// Register adds every internal collector to reg. Calling it more than once is
+// a no-op so tests and callers that re-initialize the HTTP server don't panic
+// on duplicate registration.
+func (m *InternalMetrics) Register(reg *prometheus.Registry) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-
-	if _, ok := m.eventsReceived[instance]; !ok {
-		m.eventsReceived[instance] = make(map[string]*int64)
+	if m.registered {
+		return nil
+	}
+
+	collectors := []prometheus.Collector{
+		m.eventsReceived,
+		m.eventsProcessed,
+		m.eventProcessingLatency,
+		m.rtcpMessagesProcessed,
+		m.qosMessagesGenerated,
+		m.storageOperations,
+		m.fsConnections,
+	}
+	for _, c := range collectors {
+		if err := reg.Register(c); err != nil {
+			return err
+		}
 	}
-	if _, ok := m.eventsReceived[instance][eventType]; !ok {
-		var counter int64
-		m.eventsReceived[instance][eventType] = &counter
+	m.registered = true
+	return nil
+}
+
+// sanitizeLabel guards against empty or control-character label values
+// producing malformed Prometheus exposition output.
+func sanitizeLabel(value string) string {
+	if value == "" {
+		return "unknown"
 	}
-	atomic.AddInt64(m.eventsReceived[instance][eventType], 1)
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return '_'
+		}
+		return r
+	}, value)
+}
+
+// IncrementEventsReceived increments the events received counter
+func (m *InternalMetrics) IncrementEventsReceived(instance, eventType string) {
+	m.eventsReceived.WithLabelValues(sanitizeLabel(instance), sanitizeLabel(eventType)).Inc()
 }
 
 // IncrementEventsProcessed increments the events processed counter
 func (m *InternalMetrics) IncrementEventsProcessed(instance, eventType string) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	m.eventsProcessed.WithLabelValues(sanitizeLabel(instance), sanitizeLabel(eventType)).Inc()
+}
 
-	if _, ok := m.eventsProcessed[instance]; !ok {
-		m.eventsProcessed[instance] = make(map[string]*int64)
-	}
-	if _, ok := m.eventsProcessed[instance][eventType]; !ok {
-		var counter int64
-		m.eventsProcessed[instance][eventType] = &counter
-	}
-	atomic.AddInt64(m.eventsProcessed[instance][eventType], 1)
+// ObserveEventProcessingLatency records how long processing a single event
+// of eventType for instance took, in seconds.
+func (m *InternalMetrics) ObserveEventProcessingLatency(instance, eventType string, seconds float64) {
+	m.eventProcessingLatency.WithLabelValues(sanitizeLabel(instance), sanitizeLabel(eventType)).Observe(seconds)
 }
 
 // IncrementRTCPMessagesProcessed increments the RTCP messages processed counter
 func (m *InternalMetrics) IncrementRTCPMessagesProcessed(instance, direction string) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	if _, ok := m.rtcpMessagesProcessed[instance]; !ok {
-		m.rtcpMessagesProcessed[instance] = make(map[string]*int64)
-	}
-	if _, ok := m.rtcpMessagesProcessed[instance][direction]; !ok {
-		var counter int64
-		m.rtcpMessagesProcessed[instance][direction] = &counter
-	}
-	atomic.AddInt64(m.rtcpMessagesProcessed[instance][direction], 1)
+	m.rtcpMessagesProcessed.WithLabelValues(sanitizeLabel(instance), sanitizeLabel(direction)).Inc()
 }
 
 // IncrementQoSMessagesGenerated increments the QoS messages generated counter
 func (m *InternalMetrics) IncrementQoSMessagesGenerated(instance string) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	if _, ok := m.qosMessagesGenerated[instance]; !ok {
-		var counter int64
-		m.qosMessagesGenerated[instance] = &counter
-	}
-	atomic.AddInt64(m.qosMessagesGenerated[instance], 1)
+	m.qosMessagesGenerated.WithLabelValues(sanitizeLabel(instance)).Inc()
 }
 
 // IncrementStorageOperations increments the storage operations counter
 func (m *InternalMetrics) IncrementStorageOperations(operation, status string) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	if _, ok := m.storageOperations[operation]; !ok {
-		m.storageOperations[operation] = make(map[string]*int64)
-	}
-	if _, ok := m.storageOperations[operation][status]; !ok {
-		var counter int64
-		m.storageOperations[operation][status] = &counter
-	}
-	atomic.AddInt64(m.storageOperations[operation][status], 1)
+	m.storageOperations.WithLabelValues(sanitizeLabel(operation), sanitizeLabel(status)).Inc()
 }
 
 // SetFSConnectionStatus sets the connection status for a FreeSWITCH instance
 func (m *InternalMetrics) SetFSConnectionStatus(instance string, connected bool) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	if _, ok := m.fsConnections[instance]; !ok {
-		var status int64
-		m.fsConnections[instance] = &status
-	}
+	value := 0.0
 	if connected {
-		atomic.StoreInt64(m.fsConnections[instance], 1)
-	} else {
-		atomic.StoreInt64(m.fsConnections[instance], 0)
-	}
-}
-
-// GetPrometheusMetrics returns metrics in Prometheus format
-func (m *InternalMetrics) GetPrometheusMetrics() string {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
-	var output string
-
-	// Events received
-	output += "# HELP fsagent_events_received_total Total number of events received from FreeSWITCH\n"
-	output += "# TYPE fsagent_events_received_total counter\n"
-	for instance, eventTypes := range m.eventsReceived {
-		for eventType, counter := range eventTypes {
-			count := atomic.LoadInt64(counter)
-			output += fmt.Sprintf("fsagent_events_received_total{instance=\"%s\",event_type=\"%s\"} %d\n", instance, eventType, count)
-		}
-	}
-
-	// Events processed
-	output += "# HELP fsagent_events_processed_total Total number of events processed successfully\n"
-	output += "# TYPE fsagent_events_processed_total counter\n"
-	for instance, eventTypes := range m.eventsProcessed {
-		for eventType, counter := range eventTypes {
-			count := atomic.LoadInt64(counter)
-			output += fmt.Sprintf("fsagent_events_processed_total{instance=\"%s\",event_type=\"%s\"} %d\n", instance, eventType, count)
-		}
-	}
-
-	// RTCP messages processed
-	output += "# HELP fsagent_rtcp_messages_processed_total Total number of RTCP messages processed\n"
-	output += "# TYPE fsagent_rtcp_messages_processed_total counter\n"
-	for instance, directions := range m.rtcpMessagesProcessed {
-		for direction, counter := range directions {
-			count := atomic.LoadInt64(counter)
-			output += fmt.Sprintf("fsagent_rtcp_messages_processed_total{instance=\"%s\",direction=\"%s\"} %d\n", instance, direction, count)
-		}
-	}
-
-	// QoS messages generated
-	output += "# HELP fsagent_qos_messages_generated_total Total number of QoS messages generated\n"
-	output += "# TYPE fsagent_qos_messages_generated_total counter\n"
-	for instance, counter := range m.qosMessagesGenerated {
-		count := atomic.LoadInt64(counter)
-		output += fmt.Sprintf("fsagent_qos_messages_generated_total{instance=\"%s\"} %d\n", instance, count)
+		value = 1.0
 	}
-
-	// Storage operations
-	output += "# HELP fsagent_storage_operations_total Total number of storage operations\n"
-	output += "# TYPE fsagent_storage_operations_total counter\n"
-	for operation, statuses := range m.storageOperations {
-		for status, counter := range statuses {
-			count := atomic.LoadInt64(counter)
-			output += fmt.Sprintf("fsagent_storage_operations_total{operation=\"%s\",status=\"%s\"} %d\n", operation, status, count)
-		}
-	}
-
-	// FS connections
-	output += "# HELP fsagent_fs_connections FreeSWITCH connection status (1=connected, 0=disconnected)\n"
-	output += "# TYPE fsagent_fs_connections gauge\n"
-	for instance, status := range m.fsConnections {
-		statusValue := atomic.LoadInt64(status)
-		statusLabel := "disconnected"
-		if statusValue == 1 {
-			statusLabel = "connected"
-		}
-		output += fmt.Sprintf("fsagent_fs_connections{instance=\"%s\",status=\"%s\"} %d\n", instance, statusLabel, statusValue)
-	}
-
-	return output
+	m.fsConnections.WithLabelValues(sanitizeLabel(instance)).Set(value)
 }